@@ -0,0 +1,42 @@
+package bp
+
+import (
+	"unsafe"
+)
+
+// alignUp rounds size up to multiple of align
+func alignUp(size, align int) int {
+	if align < 2 {
+		return size
+	}
+	return (size + align - 1) / align * align
+}
+
+// alignOffset returns offset of buf where address is multiple of align
+func alignOffset(buf []byte, align int) int {
+	if align < 2 || cap(buf) < 1 {
+		return 0
+	}
+	addr := uintptr(unsafe.Pointer(&buf[:1][0]))
+	if r := int(addr % uintptr(align)); r != 0 {
+		return align - r
+	}
+	return 0
+}
+
+func isAligned(data []byte, align int) bool {
+	if cap(data) < 1 {
+		return true
+	}
+	return alignOffset(data, align) == 0
+}
+
+// alignedBytes returns []byte of size whose address is multiple of align
+func alignedBytes(size, align int) []byte {
+	if align < 2 {
+		return make([]byte, size)
+	}
+	buf := make([]byte, size+align-1)
+	offset := alignOffset(buf, align)
+	return buf[offset : offset+size : offset+size]
+}
@@ -3,6 +3,7 @@ package bp
 import (
 	"bufio"
 	"bytes"
+	"image"
 	"io"
 	"time"
 )
@@ -12,6 +13,12 @@ type PoolSize interface {
 	Cap() int
 }
 
+type GetPut[T any] interface {
+	GetRef() *ObjectRef[T]
+	Get() T
+	Put(T) bool
+}
+
 type ByteGetPut interface {
 	GetRef() *ByteRef
 	Get() []byte
@@ -46,6 +53,24 @@ type ImageNRGBAGetPut interface {
 	Put([]byte) bool
 }
 
+// ImageGetPut is pool of image that has pixels in []byte
+type ImageGetPut[I image.Image] interface {
+	GetRef() *ImageRef[I]
+	Put([]byte) bool
+}
+
+type (
+	ImageGrayGetPut     = ImageGetPut[*image.Gray]
+	ImageGray16GetPut   = ImageGetPut[*image.Gray16]
+	ImageAlphaGetPut    = ImageGetPut[*image.Alpha]
+	ImageAlpha16GetPut  = ImageGetPut[*image.Alpha16]
+	ImageRGBA64GetPut   = ImageGetPut[*image.RGBA64]
+	ImageNRGBA64GetPut  = ImageGetPut[*image.NRGBA64]
+	ImageCMYKGetPut     = ImageGetPut[*image.CMYK]
+	ImagePalettedGetPut = ImageGetPut[*image.Paletted]
+	ImageNYCbCrAGetPut  = ImageGetPut[*image.NYCbCrA]
+)
+
 type ImageYCbCrGetPut interface {
 	GetRef() *ImageYCbCrRef
 	Put([]byte) bool
@@ -0,0 +1,126 @@
+package bp
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Budget bounds the total bytes retained by pools attached with AttachBudget,
+// Put is discarded when the pooled object would exceed the limit.
+type Budget struct {
+	limit  int64
+	used   int64
+	mutex  *sync.Mutex
+	shares map[string]*int64
+	names  []string
+}
+
+// BudgetShare is retained bytes of pools attached by name
+type BudgetShare struct {
+	Name  string
+	Used  int64
+	Ratio float64 // Used / Limit
+}
+
+func (b *Budget) Limit() int64 {
+	return b.limit
+}
+
+// Used returns total bytes retained by all attached pools
+func (b *Budget) Used() int64 {
+	return atomic.LoadInt64(&b.used)
+}
+
+// Shares returns retained bytes of each name, sorted by name
+func (b *Budget) Shares() []BudgetShare {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	shares := make([]BudgetShare, 0, len(b.names))
+	for _, name := range b.names {
+		used := atomic.LoadInt64(b.shares[name])
+		ratio := float64(0)
+		if 0 < b.limit {
+			ratio = float64(used) / float64(b.limit)
+		}
+		shares = append(shares, BudgetShare{
+			Name:  name,
+			Used:  used,
+			Ratio: ratio,
+		})
+	}
+	return shares
+}
+
+func (b *Budget) share(name string) *int64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if s, ok := b.shares[name]; ok {
+		// multi pools share the same name
+		return s
+	}
+	s := new(int64)
+	b.shares[name] = s
+	b.names = append(b.names, name)
+	sort.Strings(b.names)
+	return s
+}
+
+func (b *Budget) reserve(size int64) bool {
+	for {
+		used := atomic.LoadInt64(&b.used)
+		if b.limit < used+size {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&b.used, used, used+size) {
+			return true
+		}
+	}
+}
+
+func (b *Budget) release(size int64) {
+	atomic.AddInt64(&b.used, -1*size)
+}
+
+func NewBudget(limit int64) *Budget {
+	return &Budget{
+		limit:  limit,
+		mutex:  new(sync.Mutex),
+		shares: make(map[string]*int64),
+		names:  make([]string, 0),
+	}
+}
+
+// budgetAccount accounts bytes of objects retained by a pool
+type budgetAccount struct {
+	budget *Budget
+	share  *int64
+	size   int64 // bytes per pooled object
+}
+
+func (a *budgetAccount) reserve() bool {
+	if a.budget.reserve(a.size) != true {
+		return false
+	}
+	atomic.AddInt64(a.share, a.size)
+	return true
+}
+
+func (a *budgetAccount) release() {
+	atomic.AddInt64(a.share, -1*a.size)
+	a.budget.release(a.size)
+}
+
+// newBudgetAccount returns nil if Budget is not attached
+func newBudgetAccount(opt *option, size int) *budgetAccount {
+	if opt.budget == nil {
+		return nil
+	}
+	return &budgetAccount{
+		budget: opt.budget,
+		share:  opt.budget.share(opt.budgetName),
+		size:   int64(size),
+	}
+}
@@ -0,0 +1,105 @@
+package bp
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestBudget(t *testing.T) {
+	t.Run("discard", func(tt *testing.T) {
+		budget := NewBudget(8 * 3)
+		p := NewBytePool(10, 8, AttachBudget(budget, "byte"))
+		for i := 0; i < 5; i += 1 {
+			p.Put(make([]byte, 8))
+		}
+		if p.Len() != 3 {
+			tt.Errorf("retained within budget: %d", p.Len())
+		}
+		if budget.Used() != 8*3 {
+			tt.Errorf("used = %d", budget.Used())
+		}
+		if s := p.Stats(); s.PutDiscardBudget != 2 {
+			tt.Errorf("discard by budget = %d", s.PutDiscardBudget)
+		}
+
+		p.Get()
+		if budget.Used() != 8*2 {
+			tt.Errorf("released by Get: %d", budget.Used())
+		}
+		if p.Put(make([]byte, 8)) != true {
+			tt.Errorf("free budget")
+		}
+	})
+	t.Run("shared", func(tt *testing.T) {
+		budget := NewBudget(100)
+		p1 := NewBytePool(10, 10, AttachBudget(budget, "p1"))
+		p2 := NewBufferPool(10, 20, AttachBudget(budget, "p2"))
+		for i := 0; i < 4; i += 1 {
+			p1.Put(make([]byte, 10))
+		}
+		for i := 0; i < 4; i += 1 {
+			p2.Put(bytes.NewBuffer(make([]byte, 0, 20)))
+		}
+		if p1.Len() != 4 {
+			tt.Errorf("p1 len = %d", p1.Len())
+		}
+		// 40 + 20 * 3
+		if p2.Len() != 3 {
+			tt.Errorf("p2 len = %d", p2.Len())
+		}
+
+		shares := budget.Shares()
+		if len(shares) != 2 {
+			tt.Fatalf("shares = %v", shares)
+		}
+		if shares[0].Name != "p1" || shares[0].Used != 40 || shares[0].Ratio != 0.4 {
+			tt.Errorf("p1 share = %+v", shares[0])
+		}
+		if shares[1].Name != "p2" || shares[1].Used != 60 || shares[1].Ratio != 0.6 {
+			tt.Errorf("p2 share = %+v", shares[1])
+		}
+	})
+	t.Run("multi", func(tt *testing.T) {
+		budget := NewBudget(1000)
+		mp := NewMultiBufferPool(
+			MultiBufferPoolSize(10, 8),
+			MultiBufferPoolSize(10, 16),
+			MultiBufferPoolOption(AttachBudget(budget, "multi")),
+		)
+		mp.Put(mp.Get(8))
+		mp.Put(mp.Get(16))
+
+		shares := budget.Shares()
+		if len(shares) != 1 {
+			tt.Fatalf("same name: %v", shares)
+		}
+		if shares[0].Used != 8+16 {
+			tt.Errorf("used = %d", shares[0].Used)
+		}
+	})
+	t.Run("image", func(tt *testing.T) {
+		rect := image.Rect(0, 0, 10, 10)
+		budget := NewBudget(10 * 10 * 4)
+		p := NewImageRGBAPool(10, rect, AttachBudget(budget, "image"))
+		r1 := p.GetRef()
+		r2 := p.GetRef()
+		r1.Release()
+		r2.Release()
+		if p.Len() != 1 {
+			tt.Errorf("len = %d", p.Len())
+		}
+	})
+	t.Run("trim", func(tt *testing.T) {
+		budget := NewBudget(100)
+		p := NewBytePool(10, 10, AttachBudget(budget, "trim"))
+		for i := 0; i < 5; i += 1 {
+			p.Put(make([]byte, 10))
+		}
+		p.Trim()
+		p.Trim()
+		if budget.Used() != 0 {
+			tt.Errorf("released by Trim: %d", budget.Used())
+		}
+	})
+}
@@ -2,13 +2,15 @@ package bp
 
 import (
 	"bytes"
+	"context"
 )
 
 type BufferPool struct {
-	pool        chan *bytes.Buffer
+	pool        *Pool[*bytes.Buffer]
 	bufSize     int
 	maxBufSize  int
 	autoGrowCap bool
+	zero        ZeroPolicy
 }
 
 func (b *BufferPool) GetRef() *BufferRef {
@@ -19,27 +21,55 @@ func (b *BufferPool) GetRef() *BufferRef {
 	return ref
 }
 
-func (b *BufferPool) preload(rate float64) {
-	if 0 < cap(b.pool) {
-		preloadSize := int(float64(cap(b.pool)) * rate)
-		for i := 0; i < preloadSize; i += 1 {
-			b.Put(bytes.NewBuffer(make([]byte, 0, b.bufSize)))
-		}
+func (b *BufferPool) GetRefContext(ctx context.Context) (*BufferRef, error) {
+	data, err := b.GetContext(ctx)
+	if err != nil {
+		return nil, err
 	}
+
+	ref := newBufferRef(data, b)
+	ref.setFinalizer()
+	return ref, nil
 }
 
-func (b *BufferPool) Get() *bytes.Buffer {
-	var data *bytes.Buffer
-	select {
-	case data = <-b.pool:
-		// reuse exists pool
-	default:
-		// create *bytes.Buffer w/ []byte
-		data = bytes.NewBuffer(make([]byte, 0, b.bufSize))
+func (b *BufferPool) create() *bytes.Buffer {
+	// create *bytes.Buffer w/ []byte
+	return bytes.NewBuffer(make([]byte, 0, b.bufSize))
+}
+
+func (b *BufferPool) reset(data *bytes.Buffer) *bytes.Buffer {
+	if b.zero.onPut() {
+		zeroBuffer(data)
 	}
+	b.autoGrow(data)
+
+	data.Reset()
+	return data
+}
+
+func (b *BufferPool) Get() *bytes.Buffer {
+	data, _ := b.GetContext(context.Background())
 	return data
 }
 
+// GetContext waits until ctx is done if MaxOutstanding is reached
+func (b *BufferPool) GetContext(ctx context.Context) (*bytes.Buffer, error) {
+	data, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if b.zero.onGet() {
+		zeroBuffer(data)
+	}
+	return data, nil
+}
+
+// zeroBuffer wipes whole underlying []byte of data, including read part
+func zeroBuffer(data *bytes.Buffer) {
+	data.Reset()
+	zeroBytes(data.Bytes())
+}
+
 func (b *BufferPool) autoGrow(data *bytes.Buffer) {
 	if b.autoGrowCap != true {
 		return
@@ -51,32 +81,56 @@ func (b *BufferPool) autoGrow(data *bytes.Buffer) {
 	}
 }
 
+func (b *BufferPool) releaseLent(data *bytes.Buffer) {
+	b.pool.releaseLent(data)
+}
+
 func (b *BufferPool) Put(data *bytes.Buffer) bool {
+	defer b.pool.release(data)
+
 	if b.maxBufSize < data.Cap() {
 		// discard, dont keep too big size buffer in heap and release it
+		b.pool.stats.discardTooBig()
 		return false
 	}
 
-	b.autoGrow(data)
-
-	data.Reset()
-
-	select {
-	case b.pool <- data:
-		// free capacity
-		return true
-	default:
-		// full capacity, discard it
-		return false
-	}
+	return b.pool.store(data)
 }
 
 func (b *BufferPool) Len() int {
-	return len(b.pool)
+	return b.pool.Len()
 }
 
 func (b *BufferPool) Cap() int {
-	return cap(b.pool)
+	return b.pool.Cap()
+}
+
+func (b *BufferPool) Stats() Stats {
+	return b.pool.Stats()
+}
+
+func (b *BufferPool) Trim() int {
+	return b.pool.Trim()
+}
+
+func (b *BufferPool) Close() error {
+	return b.pool.Close()
+}
+
+func (b *BufferPool) Drain(ctx context.Context) error {
+	return b.pool.Drain(ctx)
+}
+
+func (b *BufferPool) poolStats() *poolStats {
+	return b.pool.stats
+}
+
+func (b *BufferPool) leakDetector() *leakDetector {
+	return b.pool.leak
+}
+
+func (b *BufferPool) metricsLabels() []metricsLabel {
+	return bufSizeMetricsLabels(b.bufSize)
 }
 
 func NewBufferPool(poolSize int, bufSize int, funcs ...optionFunc) *BufferPool {
@@ -86,22 +140,25 @@ func NewBufferPool(poolSize int, bufSize int, funcs ...optionFunc) *BufferPool {
 	}
 
 	b := &BufferPool{
-		pool:       make(chan *bytes.Buffer, poolSize),
 		bufSize:    bufSize,
 		maxBufSize: int(opt.maxBufSizeFactor * float64(bufSize)),
+		zero:       opt.zeroPolicy,
 	}
+	b.pool = newPool[*bytes.Buffer](poolSize, b.create, opt)
+	b.pool.budget = newBudgetAccount(opt, bufSize)
+	b.pool.resetFunc = b.reset
 
 	if b.maxBufSize < 1 {
 		b.maxBufSize = bufSize
 	}
 
-	if opt.preload {
-		b.preload(opt.preloadRate)
-	}
-
 	if opt.autoGrow {
 		b.autoGrowCap = opt.autoGrow
 	}
 
+	if opt.preload {
+		b.pool.preload(opt.preloadRate)
+	}
+
 	return b
 }
@@ -329,3 +329,34 @@ func TestBufferPoolPreload(t *testing.T) {
 		t.Errorf("preloaded buffer = %d", p.Len())
 	}
 }
+
+func TestBufferPoolZeroing(t *testing.T) {
+	isZero := func(data *bytes.Buffer) bool {
+		data.Reset()
+		for _, c := range data.Bytes()[:data.Cap()] {
+			if c != 0 {
+				return false
+			}
+		}
+		return true
+	}
+	t.Run("put", func(tt *testing.T) {
+		p := NewBufferPool(1, 8, Zeroing(ZeroOnPut))
+		b := p.Get()
+		b.WriteString("secret")
+		b.Next(3) // wipe read part too
+		p.Put(b)
+		if isZero(b) != true {
+			tt.Errorf("wiped on put")
+		}
+	})
+	t.Run("get", func(tt *testing.T) {
+		p := NewBufferPool(1, 8, Zeroing(ZeroOnGet))
+		b := p.Get()
+		b.WriteString("secret")
+		p.Put(b)
+		if isZero(p.Get()) != true {
+			tt.Errorf("wiped on get")
+		}
+	})
+}
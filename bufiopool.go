@@ -2,6 +2,7 @@ package bp
 
 import (
 	"bufio"
+	"context"
 	"io"
 )
 
@@ -10,7 +11,7 @@ const (
 )
 
 type BufioReaderPool struct {
-	pool    chan *bufio.Reader
+	pool    *Pool[*bufio.Reader]
 	bufSize int
 	strict  bool
 }
@@ -23,59 +24,77 @@ func (b *BufioReaderPool) GetRef(r io.Reader) *BufioReaderRef {
 	return ref
 }
 
-func (b *BufioReaderPool) preload(rate float64) {
-	if 0 < cap(b.pool) {
-		preloadSize := int(float64(cap(b.pool)) * rate)
-		for i := 0; i < preloadSize; i += 1 {
-			b.Put(bufio.NewReaderSize(nil, b.bufSize))
-		}
-	}
+func (b *BufioReaderPool) create() *bufio.Reader {
+	// create *bufio.Reader
+	return bufio.NewReaderSize(nil, b.bufSize)
 }
 
 func (b *BufioReaderPool) Get(r io.Reader) *bufio.Reader {
-	var br *bufio.Reader
-	select {
-	case br = <-b.pool:
-		// reuse exists pool
-	default:
-		// create *bufio.Reader
-		br = bufio.NewReaderSize(nil, b.bufSize)
-	}
+	br := b.pool.Get()
 	br.Reset(r)
 	return br
 }
 
+func (b *BufioReaderPool) releaseLent(data *bufio.Reader) {
+	b.pool.releaseLent(data)
+}
+
 func (b *BufioReaderPool) Put(br *bufio.Reader) bool {
+	defer b.pool.release(br)
+
 	br.Reset(nil)
 
 	if br.Size() < b.bufSize {
 		// discard
+		b.pool.stats.discardTooSmall()
 		return false
 	}
 
 	if b.bufSize < br.Size() {
 		if b.strict {
 			// discard, same buffer size only
+			b.pool.stats.discardTooBig()
 			return false
 		}
 	}
 
-	select {
-	case b.pool <- br:
-		// free capacity
-		return true
-	default:
-		// full capacity, discard it
-		return false
-	}
+	return b.pool.store(br)
 }
 
 func (b *BufioReaderPool) Len() int {
-	return len(b.pool)
+	return b.pool.Len()
 }
 
 func (b *BufioReaderPool) Cap() int {
-	return cap(b.pool)
+	return b.pool.Cap()
+}
+
+func (b *BufioReaderPool) Stats() Stats {
+	return b.pool.Stats()
+}
+
+func (b *BufioReaderPool) Trim() int {
+	return b.pool.Trim()
+}
+
+func (b *BufioReaderPool) Close() error {
+	return b.pool.Close()
+}
+
+func (b *BufioReaderPool) Drain(ctx context.Context) error {
+	return b.pool.Drain(ctx)
+}
+
+func (b *BufioReaderPool) poolStats() *poolStats {
+	return b.pool.stats
+}
+
+func (b *BufioReaderPool) leakDetector() *leakDetector {
+	return b.pool.leak
+}
+
+func (b *BufioReaderPool) metricsLabels() []metricsLabel {
+	return bufSizeMetricsLabels(b.bufSize)
 }
 
 func NewBufioReaderPool(poolSize int, funcs ...optionFunc) *BufioReaderPool {
@@ -93,19 +112,20 @@ func newBufioReaderPool(poolSize int, bufSize int, sizeStrict bool, funcs ...opt
 	}
 
 	b := &BufioReaderPool{
-		pool:    make(chan *bufio.Reader, poolSize),
 		bufSize: bufSize,
 		strict:  sizeStrict,
 	}
+	b.pool = newPool[*bufio.Reader](poolSize, b.create, opt)
+	b.pool.budget = newBudgetAccount(opt, bufSize)
 
 	if opt.preload {
-		b.preload(opt.preloadRate)
+		b.pool.preload(opt.preloadRate)
 	}
 	return b
 }
 
 type BufioWriterPool struct {
-	pool    chan *bufio.Writer
+	pool    *Pool[*bufio.Writer]
 	bufSize int
 	strict  bool
 }
@@ -118,59 +138,77 @@ func (b *BufioWriterPool) GetRef(w io.Writer) *BufioWriterRef {
 	return ref
 }
 
-func (b *BufioWriterPool) preload(rate float64) {
-	if 0 < cap(b.pool) {
-		preloadSize := int(float64(cap(b.pool)) * rate)
-		for i := 0; i < preloadSize; i += 1 {
-			b.Put(bufio.NewWriterSize(nil, b.bufSize))
-		}
-	}
+func (b *BufioWriterPool) create() *bufio.Writer {
+	// create *bufio.Writer
+	return bufio.NewWriterSize(nil, b.bufSize)
 }
 
 func (b *BufioWriterPool) Get(w io.Writer) *bufio.Writer {
-	var bw *bufio.Writer
-	select {
-	case bw = <-b.pool:
-		// reuse exists pool
-	default:
-		// create *bufio.Writer
-		bw = bufio.NewWriterSize(nil, b.bufSize)
-	}
+	bw := b.pool.Get()
 	bw.Reset(w)
 	return bw
 }
 
+func (b *BufioWriterPool) releaseLent(data *bufio.Writer) {
+	b.pool.releaseLent(data)
+}
+
 func (b *BufioWriterPool) Put(bw *bufio.Writer) bool {
+	defer b.pool.release(bw)
+
 	bw.Reset(nil)
 
 	if bw.Size() < b.bufSize {
 		// discard
+		b.pool.stats.discardTooSmall()
 		return false
 	}
 
 	if b.bufSize < bw.Size() {
 		if b.strict {
 			// discard, same buffer size only
+			b.pool.stats.discardTooBig()
 			return false
 		}
 	}
 
-	select {
-	case b.pool <- bw:
-		// free capacity
-		return true
-	default:
-		// full capacity, discard it
-		return false
-	}
+	return b.pool.store(bw)
 }
 
 func (b *BufioWriterPool) Len() int {
-	return len(b.pool)
+	return b.pool.Len()
 }
 
 func (b *BufioWriterPool) Cap() int {
-	return cap(b.pool)
+	return b.pool.Cap()
+}
+
+func (b *BufioWriterPool) Stats() Stats {
+	return b.pool.Stats()
+}
+
+func (b *BufioWriterPool) Trim() int {
+	return b.pool.Trim()
+}
+
+func (b *BufioWriterPool) Close() error {
+	return b.pool.Close()
+}
+
+func (b *BufioWriterPool) Drain(ctx context.Context) error {
+	return b.pool.Drain(ctx)
+}
+
+func (b *BufioWriterPool) poolStats() *poolStats {
+	return b.pool.stats
+}
+
+func (b *BufioWriterPool) leakDetector() *leakDetector {
+	return b.pool.leak
+}
+
+func (b *BufioWriterPool) metricsLabels() []metricsLabel {
+	return bufSizeMetricsLabels(b.bufSize)
 }
 
 func NewBufioWriterPool(poolSize int, funcs ...optionFunc) *BufioWriterPool {
@@ -188,13 +226,14 @@ func newBufioWriterPool(poolSize int, bufSize int, sizeStrict bool, funcs ...opt
 	}
 
 	b := &BufioWriterPool{
-		pool:    make(chan *bufio.Writer, poolSize),
 		bufSize: bufSize,
 		strict:  sizeStrict,
 	}
+	b.pool = newPool[*bufio.Writer](poolSize, b.create, opt)
+	b.pool.budget = newBudgetAccount(opt, bufSize)
 
 	if opt.preload {
-		b.preload(opt.preloadRate)
+		b.pool.preload(opt.preloadRate)
 	}
 	return b
 }
@@ -1,9 +1,16 @@
 package bp
 
+import (
+	"context"
+)
+
 type BytePool struct {
-	pool       chan []byte
+	pool       *Pool[[]byte]
 	bufSize    int
 	maxBufSize int
+	alignment  int // 0 if not aligned
+	zero       ZeroPolicy
+	prov       *provenance
 }
 
 func (b *BytePool) GetRef() *ByteRef {
@@ -14,53 +21,138 @@ func (b *BytePool) GetRef() *ByteRef {
 	return ref
 }
 
-func (b *BytePool) preload(rate float64) {
-	if 0 < cap(b.pool) {
-		preloadSize := int(float64(cap(b.pool)) * rate)
-		for i := 0; i < preloadSize; i += 1 {
-			b.Put(make([]byte, b.bufSize))
-		}
+func (b *BytePool) GetRefContext(ctx context.Context) (*ByteRef, error) {
+	data, err := b.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := newByteRef(data, b)
+	ref.setFinalizer()
+	return ref, nil
+}
+
+func (b *BytePool) create() []byte {
+	// create []byte
+	return alignedBytes(b.bufSize, b.alignment)
+}
+
+func (b *BytePool) reset(data []byte) []byte {
+	if b.zero.onPut() {
+		zeroBytes(data)
 	}
+	return data[:b.bufSize:b.bufSize]
 }
 
 func (b *BytePool) Get() []byte {
-	select {
-	case data := <-b.pool:
-		// reuse exists pool
-		return data[:b.bufSize]
-	default:
-		// create []byte
-		return make([]byte, b.bufSize)
+	data, _ := b.GetContext(context.Background())
+	return data
+}
+
+// GetContext waits until ctx is done if MaxOutstanding is reached
+func (b *BytePool) GetContext(ctx context.Context) ([]byte, error) {
+	data, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
 	}
+	if b.zero.onGet() {
+		zeroBytes(data)
+	}
+	if b.prov != nil {
+		b.prov.lend(data)
+	}
+	return data, nil
+}
+
+func (b *BytePool) releaseLent(data []byte) {
+	b.pool.releaseLent(data)
 }
 
 func (b *BytePool) Put(data []byte) bool {
+	defer b.pool.release(data)
+
 	if b.maxBufSize < cap(data) {
 		// discard, dont keep too big size byte in heap and release it
+		b.pool.stats.discardTooBig()
+		b.forget(data)
 		return false
 	}
 
 	if cap(data) < b.bufSize {
 		// discard small buffer
+		b.pool.stats.discardTooSmall()
+		b.forget(data)
 		return false
 	}
 
-	select {
-	case b.pool <- data[:b.bufSize:b.bufSize]:
-		// free capacity
+	if 0 < b.alignment {
+		if isAligned(data, b.alignment) != true {
+			// discard, not allocated by this pool
+			b.pool.stats.discardForeign()
+			b.forget(data)
+			return false
+		}
+	}
+
+	if b.prov != nil {
+		if b.prov.put(data, b.pool.stats) != true {
+			return false
+		}
+	}
+
+	if b.pool.store(data) {
 		return true
-	default:
-		// full capacity, discard it
-		return false
+	}
+	b.forget(data)
+	return false
+}
+
+// forget stops tracking provenance of discarded data
+func (b *BytePool) forget(data []byte) {
+	if b.prov != nil {
+		b.prov.forget(data)
 	}
 }
 
 func (b *BytePool) Len() int {
-	return len(b.pool)
+	return b.pool.Len()
 }
 
 func (b *BytePool) Cap() int {
-	return cap(b.pool)
+	return b.pool.Cap()
+}
+
+// Alignment returns alignment of buffers, 0 if Alignment is not set
+func (b *BytePool) Alignment() int {
+	return b.alignment
+}
+
+func (b *BytePool) Stats() Stats {
+	return b.pool.Stats()
+}
+
+func (b *BytePool) Trim() int {
+	return b.pool.Trim()
+}
+
+func (b *BytePool) Close() error {
+	return b.pool.Close()
+}
+
+func (b *BytePool) Drain(ctx context.Context) error {
+	return b.pool.Drain(ctx)
+}
+
+func (b *BytePool) poolStats() *poolStats {
+	return b.pool.stats
+}
+
+func (b *BytePool) leakDetector() *leakDetector {
+	return b.pool.leak
+}
+
+func (b *BytePool) metricsLabels() []metricsLabel {
+	return bufSizeMetricsLabels(b.bufSize)
 }
 
 func NewBytePool(poolSize int, bufSize int, funcs ...optionFunc) *BytePool {
@@ -69,10 +161,24 @@ func NewBytePool(poolSize int, bufSize int, funcs ...optionFunc) *BytePool {
 		fn(opt)
 	}
 
+	if 1 < opt.alignment {
+		bufSize = alignUp(bufSize, opt.alignment)
+	}
+
 	b := &BytePool{
-		pool:       make(chan []byte, poolSize),
 		bufSize:    bufSize,
 		maxBufSize: int(opt.maxBufSizeFactor * float64(bufSize)),
+		zero:       opt.zeroPolicy,
+		prov:       newProvenance(poolSize, opt),
+	}
+	if 1 < opt.alignment {
+		b.alignment = opt.alignment
+	}
+	b.pool = newPool[[]byte](poolSize, b.create, opt)
+	b.pool.budget = newBudgetAccount(opt, bufSize)
+	b.pool.resetFunc = b.reset
+	if b.prov != nil {
+		b.pool.dispose = b.prov.forget
 	}
 
 	if b.maxBufSize < 1 {
@@ -80,7 +186,7 @@ func NewBytePool(poolSize int, bufSize int, funcs ...optionFunc) *BytePool {
 	}
 
 	if opt.preload {
-		b.preload(opt.preloadRate)
+		b.pool.preload(opt.preloadRate)
 	}
 
 	return b
@@ -0,0 +1,327 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package bp
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync/atomic"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	fileMmapFlag          = unix.MAP_SHARED
+	fileMmapPattern       = "bp-*.mmap"
+	fileMmapZeroChunkSize = 1024 * 1024
+)
+
+const (
+	slotPooled int32 = iota
+	slotLocal        // lent by Get
+	slotRemote       // handed over to another process
+)
+
+var (
+	ErrFileMmapBytePoolClosed = errors.New("FileMmapBytePool already closed")
+)
+
+// FileMmapBytePool is fixed number of slots mapped from a file with MAP_SHARED,
+// pages of buffers are written back to the file instead of swap.
+type FileMmapBytePool struct {
+	pool     *Pool[[]byte]
+	bufSize  int
+	slotSize int
+	file     *os.File
+	mem      []byte
+	base     uintptr
+	closed   int32
+	owners   []int32 // ownership of each slot, nil if not shared
+	temp     bool    // remove file on Close
+}
+
+func (b *FileMmapBytePool) GetRef() *ByteRef {
+	data := b.Get()
+
+	ref := newByteRef(data, b)
+	ref.setFinalizer()
+	return ref
+}
+
+func (b *FileMmapBytePool) GetRefContext(ctx context.Context) (*ByteRef, error) {
+	data, err := b.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := newByteRef(data, b)
+	ref.setFinalizer()
+	return ref, nil
+}
+
+func (b *FileMmapBytePool) create() []byte {
+	// all slots are in use, fallback to heap
+	return make([]byte, b.bufSize)
+}
+
+func (b *FileMmapBytePool) reset(data []byte) []byte {
+	return data[:b.bufSize]
+}
+
+// slot returns the slot that contains data, false if data is not mapped from file
+func (b *FileMmapBytePool) slot(data []byte) ([]byte, bool) {
+	i, ok := b.slotIndex(data)
+	if ok != true {
+		return nil, false
+	}
+	offset := i * b.slotSize
+	return b.mem[offset : offset+b.slotSize : offset+b.slotSize], true
+}
+
+func (b *FileMmapBytePool) slotIndex(data []byte) (int, bool) {
+	if cap(data) < 1 {
+		return 0, false
+	}
+	addr := uintptr(unsafe.Pointer(&data[:1][0]))
+	if addr < b.base || b.base+uintptr(len(b.mem)) <= addr {
+		return 0, false
+	}
+	return int(addr-b.base) / b.slotSize, true
+}
+
+// headSlotIndex returns index of slot if data is head of slot
+func (b *FileMmapBytePool) headSlotIndex(data []byte) (int, bool) {
+	i, ok := b.slotIndex(data)
+	if ok != true {
+		return 0, false
+	}
+	if cap(data) != b.slotSize {
+		// not head of slot
+		return 0, false
+	}
+	return i, true
+}
+
+// handover moves ownership of slot from local to remote
+func (b *FileMmapBytePool) handover(i int) bool {
+	return atomic.CompareAndSwapInt32(&b.owners[i], slotLocal, slotRemote)
+}
+
+// takeback returns slot released by remote to pool
+func (b *FileMmapBytePool) takeback(i int) bool {
+	if atomic.CompareAndSwapInt32(&b.owners[i], slotRemote, slotLocal) != true {
+		return false
+	}
+	offset := i * b.slotSize
+	return b.Put(b.mem[offset : offset+b.bufSize : offset+b.slotSize])
+}
+
+func (b *FileMmapBytePool) flush(data []byte, async bool) error {
+	if atomic.LoadInt32(&b.closed) == 1 {
+		return ErrFileMmapBytePoolClosed
+	}
+	s, ok := b.slot(data)
+	if ok != true {
+		// heap is not backed by file
+		return nil
+	}
+
+	flags := unix.MS_SYNC
+	if async {
+		flags = unix.MS_ASYNC
+	}
+	return unix.Msync(s, flags)
+}
+
+func (b *FileMmapBytePool) Get() []byte {
+	data, _ := b.GetContext(context.Background())
+	return data
+}
+
+// GetContext waits until ctx is done if MaxOutstanding is reached
+func (b *FileMmapBytePool) GetContext(ctx context.Context) ([]byte, error) {
+	data, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if b.owners != nil {
+		if i, ok := b.slotIndex(data); ok {
+			atomic.StoreInt32(&b.owners[i], slotLocal)
+		}
+	}
+	return data, nil
+}
+
+func (b *FileMmapBytePool) releaseLent(data []byte) {
+	b.pool.releaseLent(data)
+}
+
+func (b *FileMmapBytePool) Put(data []byte) bool {
+	defer b.pool.release(data)
+
+	if atomic.LoadInt32(&b.closed) == 1 {
+		// discard, mapping is released
+		b.pool.stats.discardClosed()
+		return false
+	}
+	i, ok := b.headSlotIndex(data)
+	if ok != true {
+		// discard, heap is released by GC
+		b.pool.stats.discardForeign()
+		return false
+	}
+	if b.owners != nil {
+		if atomic.CompareAndSwapInt32(&b.owners[i], slotLocal, slotPooled) != true {
+			// discard, already pooled or owned by remote
+			b.pool.stats.discardDuplicate()
+			return false
+		}
+	}
+	return b.pool.store(data)
+}
+
+func (b *FileMmapBytePool) Len() int {
+	return b.pool.Len()
+}
+
+func (b *FileMmapBytePool) Cap() int {
+	return b.pool.Cap()
+}
+
+func (b *FileMmapBytePool) Stats() Stats {
+	return b.pool.Stats()
+}
+
+// Path returns name of the backing file
+func (b *FileMmapBytePool) Path() string {
+	return b.file.Name()
+}
+
+// Close unmaps and removes the backing file, buffers obtained from pool must not be used after Close.
+func (b *FileMmapBytePool) Close() error {
+	if atomic.CompareAndSwapInt32(&b.closed, 0, 1) != true {
+		return ErrFileMmapBytePoolClosed
+	}
+
+	// drop slots
+	b.pool.Close()
+
+	if err := unix.Munmap(b.mem); err != nil {
+		return err
+	}
+	if err := b.file.Close(); err != nil {
+		return err
+	}
+	if b.temp {
+		return os.Remove(b.file.Name())
+	}
+	return nil
+}
+
+func (b *FileMmapBytePool) Drain(ctx context.Context) error {
+	return b.pool.Drain(ctx)
+}
+
+func (b *FileMmapBytePool) poolStats() *poolStats {
+	return b.pool.stats
+}
+
+func (b *FileMmapBytePool) leakDetector() *leakDetector {
+	return b.pool.leak
+}
+
+func (b *FileMmapBytePool) metricsLabels() []metricsLabel {
+	return bufSizeMetricsLabels(b.bufSize)
+}
+
+// NewFileMmapBytePool creates a sparse file in dir (os.TempDir() if empty) and maps poolSize slots of bufSize.
+// slots are page aligned, Get returns heap allocated []byte when all slots are in use.
+// slots are fixed for the lifetime of pool, IdleTimeout is ignored.
+func NewFileMmapBytePool(dir string, poolSize, bufSize int, funcs ...optionFunc) (*FileMmapBytePool, error) {
+	opt := newOption()
+	for _, fn := range funcs {
+		fn(opt)
+	}
+
+	f, err := os.CreateTemp(dir, fileMmapPattern)
+	if err != nil {
+		return nil, err
+	}
+	b, err := newFileMmapBytePool(f, poolSize, bufSize, opt)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	b.temp = true
+	return b, nil
+}
+
+func newFileMmapBytePool(f *os.File, poolSize, bufSize int, opt *option) (*FileMmapBytePool, error) {
+	// slots are fixed, dont release it
+	opt.idleTimeout = 0
+
+	pageSize := unix.Getpagesize()
+	slotSize := (bufSize + pageSize - 1) / pageSize * pageSize
+	if slotSize < pageSize {
+		slotSize = pageSize
+	}
+	if poolSize < 1 {
+		poolSize = 1
+	}
+	size := slotSize * poolSize
+
+	mem, err := mapFile(f, size, opt.filePreallocate)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &FileMmapBytePool{
+		bufSize:  bufSize,
+		slotSize: slotSize,
+		file:     f,
+		mem:      mem,
+		base:     uintptr(unsafe.Pointer(&mem[0])),
+	}
+	b.pool = newPool[[]byte](poolSize, b.create, opt)
+	b.pool.resetFunc = b.reset
+
+	for offset := 0; offset < size; offset += slotSize {
+		b.pool.put(mem[offset : offset+bufSize : offset+slotSize])
+	}
+	return b, nil
+}
+
+func mapFile(f *os.File, size int, preallocate bool) ([]byte, error) {
+	if err := f.Truncate(int64(size)); err != nil {
+		return nil, err
+	}
+	if preallocate {
+		if err := preallocateFile(f, int64(size)); err != nil {
+			return nil, err
+		}
+	}
+	return unix.Mmap(int(f.Fd()), 0, size, mmapPerm, fileMmapFlag)
+}
+
+func preallocateFile(f *os.File, size int64) error {
+	if err := fallocate(f, size); err == nil {
+		return nil
+	}
+
+	// fallback: allocate blocks by writing zero
+	zero := make([]byte, fileMmapZeroChunkSize)
+	for offset := int64(0); offset < size; offset += int64(len(zero)) {
+		n := size - offset
+		if int64(len(zero)) < n {
+			n = int64(len(zero))
+		}
+		if _, err := f.WriteAt(zero[:n], offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
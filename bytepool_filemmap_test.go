@@ -0,0 +1,140 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package bp
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestFileMmapBytePool(t *testing.T) {
+	pageSize := unix.Getpagesize()
+	t.Run("getput", func(tt *testing.T) {
+		p, err := NewFileMmapBytePool(tt.TempDir(), 4, 100)
+		if err != nil {
+			tt.Fatalf("no error: %+v", err)
+		}
+		defer p.Close()
+
+		if p.Len() != 4 || p.Cap() != 4 {
+			tt.Errorf("all slots are pooled: len=%d cap=%d", p.Len(), p.Cap())
+		}
+		d := p.Get()
+		if len(d) != 100 || cap(d) != pageSize {
+			tt.Errorf("page aligned slot len=%d cap=%d", len(d), cap(d))
+		}
+		if p.Put(d[:10]) != true {
+			tt.Errorf("slot")
+		}
+		if d = p.Get(); len(d) != 100 {
+			tt.Errorf("reset len = %d", len(d))
+		}
+		if p.Put(make([]byte, 100, pageSize)) {
+			tt.Errorf("heap is not slot")
+		}
+		if p.Put(d[1:]) {
+			tt.Errorf("not head of slot")
+		}
+		if s := p.Stats(); s.PutDiscardForeign != 2 {
+			tt.Errorf("foreign = %d", s.PutDiscardForeign)
+		}
+	})
+	t.Run("fallback", func(tt *testing.T) {
+		p, err := NewFileMmapBytePool(tt.TempDir(), 1, 100)
+		if err != nil {
+			tt.Fatalf("no error: %+v", err)
+		}
+		defer p.Close()
+
+		d1 := p.Get()
+		d2 := p.Get()
+		if _, ok := p.slot(d1); ok != true {
+			tt.Errorf("mapped slot")
+		}
+		if _, ok := p.slot(d2); ok {
+			tt.Errorf("all slots are in use, heap")
+		}
+		if p.Put(d2) {
+			tt.Errorf("discard heap")
+		}
+	})
+	t.Run("flush", func(tt *testing.T) {
+		p, err := NewFileMmapBytePool(tt.TempDir(), 2, 16, FilePreallocate(true))
+		if err != nil {
+			tt.Fatalf("no error: %+v", err)
+		}
+		defer p.Close()
+
+		r1 := p.GetRef()
+		r2 := p.GetRef()
+		copy(r1.B, []byte("hello"))
+		copy(r2.B, []byte("world"))
+		if err := r1.Flush(); err != nil {
+			tt.Errorf("no error: %+v", err)
+		}
+		if err := r2.FlushAsync(); err != nil {
+			tt.Errorf("no error: %+v", err)
+		}
+
+		data, err := os.ReadFile(p.Path())
+		if err != nil {
+			tt.Fatalf("no error: %+v", err)
+		}
+		if len(data) != pageSize*2 {
+			tt.Errorf("file size = %d", len(data))
+		}
+		if bytes.HasPrefix(data, []byte("hello")) != true {
+			tt.Errorf("written to file: %q", data[:5])
+		}
+		if bytes.HasPrefix(data[pageSize:], []byte("world")) != true {
+			tt.Errorf("written to file: %q", data[pageSize:pageSize+5])
+		}
+		r1.Release()
+		r2.Release()
+	})
+	t.Run("close", func(tt *testing.T) {
+		p, err := NewFileMmapBytePool(tt.TempDir(), 2, 16)
+		if err != nil {
+			tt.Fatalf("no error: %+v", err)
+		}
+		path := p.Path()
+		r := p.GetRef()
+		if err := p.Close(); err != nil {
+			tt.Errorf("no error: %+v", err)
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) != true {
+			tt.Errorf("file removed: %+v", err)
+		}
+		if err := p.Close(); err != ErrFileMmapBytePoolClosed {
+			tt.Errorf("already closed: %+v", err)
+		}
+		if err := r.Flush(); err != ErrFileMmapBytePoolClosed {
+			tt.Errorf("closed: %+v", err)
+		}
+		if p.Put(make([]byte, 16)) {
+			tt.Errorf("discard after close")
+		}
+		if s := p.Stats(); s.PutDiscardClosed != 1 {
+			tt.Errorf("put discard closed = %d", s.PutDiscardClosed)
+		}
+		if p.Len() != 0 {
+			tt.Errorf("len = %d", p.Len())
+		}
+	})
+	t.Run("heapref", func(tt *testing.T) {
+		bp := NewBytePool(1, 8)
+		r := bp.GetRef()
+		if err := r.Flush(); err != nil {
+			tt.Errorf("not file-backed: %+v", err)
+		}
+	})
+	t.Run("dir", func(tt *testing.T) {
+		if _, err := NewFileMmapBytePool("/not/exists/dir", 1, 8); err == nil {
+			tt.Errorf("dir not exists")
+		}
+	})
+}
@@ -4,7 +4,13 @@
 package bp
 
 import (
+	"context"
+	"errors"
+	"log"
 	"runtime"
+	"strings"
+	"sync/atomic"
+	"unsafe"
 
 	"golang.org/x/sys/unix"
 )
@@ -13,24 +19,243 @@ const (
 	DefaultMmapAlignment int = 8
 	mmapPerm                 = unix.PROT_READ | unix.PROT_WRITE
 	mmapFlag                 = unix.MAP_ANON | unix.MAP_PRIVATE
+
+	unsupportedMmapAlignment string = "Alignment larger than page size is not supported by MmapBytePool"
+)
+
+var (
+	errHugePageUnavailable = errors.New("transparent huge page is not enabled")
+)
+
+// MmapMode is the set of mmap features used by MmapBytePool
+type MmapMode uint32
+
+const (
+	MmapModeHugePage    MmapMode = 1 << iota // madvise(MADV_HUGEPAGE)
+	MmapModePopulate                         // mmap(MAP_POPULATE)
+	MmapModeLock                             // mlock
+	MmapModeFree                             // madvise(MADV_FREE) idle buffers
+	MmapModeDontNeed                         // madvise(MADV_DONTNEED) idle buffers
+	MmapModeGuardPage                        // PROT_NONE page after each buffer
+	MmapModeProtectIdle                      // PROT_NONE idle buffers
+	MmapModeDontDump                         // madvise(MADV_DONTDUMP) exclude from core dump
 )
 
+func (m MmapMode) String() string {
+	modes := make([]string, 0, 8)
+	if m&MmapModeHugePage != 0 {
+		modes = append(modes, "hugepage")
+	}
+	if m&MmapModePopulate != 0 {
+		modes = append(modes, "populate")
+	}
+	if m&MmapModeLock != 0 {
+		modes = append(modes, "mlock")
+	}
+	if m&MmapModeFree != 0 {
+		modes = append(modes, "free")
+	}
+	if m&MmapModeDontNeed != 0 {
+		modes = append(modes, "dontneed")
+	}
+	if m&MmapModeGuardPage != 0 {
+		modes = append(modes, "guard")
+	}
+	if m&MmapModeProtectIdle != 0 {
+		modes = append(modes, "protect")
+	}
+	if m&MmapModeDontDump != 0 {
+		modes = append(modes, "dontdump")
+	}
+	if len(modes) < 1 {
+		return "none"
+	}
+	return strings.Join(modes, "|")
+}
+
 type MmapBytePool struct {
-	pool      chan []byte
+	*mmapAllocator
+	pool *Pool[[]byte]
+	zero ZeroPolicy
+}
+
+// mmapAllocator maps buffers of MmapBytePool.
+// callbacks of pool and slab refer to mmapAllocator, not to MmapBytePool, so finalizer of MmapBytePool is not in a cycle.
+type mmapAllocator struct {
 	bufSize   int
 	alignSize int
+	bufCap    int // cap of buffer, alignSize or bufSize with guard page
+	protect   int // size of pages to protect while buffer is idle, 0 if disabled
+	alignment int // 0 if not aligned
+	hugePage  int // size of huge page that slab is aligned to, 0 if MmapHugePage is not in use
+	prov      *provenance
+	mode      uint32
+	slab      *slabArena
 }
 
-func (b *MmapBytePool) preload(rate float64) {
-	if 0 < cap(b.pool) {
-		preloadSize := int(float64(cap(b.pool)) * rate)
-		buffers, err := unix.Mmap(-1, 0, b.alignSize*preloadSize, mmapPerm, mmapFlag)
-		if err != nil {
-			buffers = make([]byte, b.alignSize*preloadSize) // fallback
+// Mode returns mmap features in use, the feature refused by kernel is removed from requested mode
+func (b *MmapBytePool) Mode() MmapMode {
+	return b.mmapMode()
+}
+
+func (b *mmapAllocator) mmapMode() MmapMode {
+	return MmapMode(atomic.LoadUint32(&b.mode))
+}
+
+func (b *mmapAllocator) fallbackMode(m MmapMode, err error) {
+	b.switchMode(m, 0, err)
+}
+
+// switchMode replaces mode from to, if from is in use
+func (b *mmapAllocator) switchMode(from, to MmapMode, err error) {
+	for {
+		mode := atomic.LoadUint32(&b.mode)
+		if mode&uint32(from) == 0 {
+			// already fallen back
+			return
+		}
+		if atomic.CompareAndSwapUint32(&b.mode, mode, (mode&^uint32(from))|uint32(to)) {
+			log.Printf("warn: bp: mmap %s is not available, fallback: %s", from, err.Error())
+			return
+		}
+	}
+}
+
+// mmap maps size bytes with current mode, fallback to heap if mmap fails (returns false)
+func (b *mmapAllocator) mmap(size int) ([]byte, bool) {
+	mode := b.mmapMode()
+
+	flag := mmapFlag
+	if mode&MmapModePopulate != 0 {
+		flag |= mmapPopulateFlag
+	}
+	buf, err := b.mmapAligned(size, flag)
+	if err != nil && mode&MmapModePopulate != 0 {
+		b.fallbackMode(MmapModePopulate, err)
+		buf, err = b.mmapAligned(size, mmapFlag)
+	}
+	if err != nil {
+		return alignedBytes(size, b.alignment), false // fallback
+	}
+
+	if mode&MmapModeHugePage != 0 {
+		if err := unix.Madvise(buf, madvHugePage); err != nil {
+			b.fallbackMode(MmapModeHugePage, err)
 		}
-		for 0 < len(buffers) {
-			b.Put(buffers[:b.bufSize:b.alignSize])
-			buffers = buffers[b.alignSize:]
+	}
+	if mode&MmapModeLock != 0 {
+		if err := unix.Mlock(buf); err != nil {
+			b.fallbackMode(MmapModeLock, err)
+		}
+	}
+	if mode&MmapModeDontDump != 0 {
+		if err := unix.Madvise(buf, madvDontDump); err != nil {
+			b.fallbackMode(MmapModeDontDump, err)
+		}
+	}
+	return buf, true
+}
+
+// mmapAligned maps size bytes at address of multiple of huge page, THP can not back unaligned mapping
+func (b *mmapAllocator) mmapAligned(size int, flag int) ([]byte, error) {
+	if b.hugePage < 1 {
+		return unix.Mmap(-1, 0, size, mmapPerm, flag)
+	}
+
+	// over-maps by a huge page, then unmaps head and tail out of aligned range
+	length := alignUp(size, unix.Getpagesize())
+	buf, err := unix.Mmap(-1, 0, length+b.hugePage, mmapPerm, flag)
+	if err != nil {
+		return nil, err
+	}
+	addr := uintptr(unsafe.Pointer(&buf[0]))
+	head := int(alignUp(int(addr), b.hugePage) - int(addr))
+	if 0 < head {
+		unix.Munmap(buf[:head])
+	}
+	if tail := buf[head+length:]; 0 < len(tail) {
+		unix.Munmap(tail)
+	}
+	return buf[head : head+size : head+length], nil
+}
+
+// slabSize rounds size up so that slots of slab cover whole huge pages, THP backs only mapping of multiple of huge page
+func (b *mmapAllocator) slabSize(size, slotSize int) int {
+	if b.hugePage < 1 {
+		return size
+	}
+	return alignUp(size, b.hugePage) + slotSize - 1
+}
+
+// reclaim tells kernel that pages of idle buffer are reclaimable, contents of buffer are discarded
+func (b *mmapAllocator) reclaim(data []byte) {
+	mode := b.mmapMode()
+	if mode&(MmapModeFree|MmapModeDontNeed) == 0 {
+		return
+	}
+	pages := mmapPages(data)
+	if len(pages) < 1 {
+		// smaller than page
+		return
+	}
+
+	if mode&MmapModeFree != 0 {
+		err := unix.Madvise(pages, madvFree)
+		if err == nil {
+			return
+		}
+		b.switchMode(MmapModeFree, MmapModeDontNeed, err)
+	}
+	if err := unix.Madvise(pages, unix.MADV_DONTNEED); err != nil {
+		b.fallbackMode(MmapModeDontNeed, err)
+	}
+}
+
+// mmapGuarded maps slab and protects the last page of each slot
+func (b *mmapAllocator) mmapGuarded(slotSize int) func(int) ([]byte, bool) {
+	pageSize := unix.Getpagesize()
+	return func(size int) ([]byte, bool) {
+		mem, mapped := b.mmap(size)
+		if mapped != true {
+			return mem, false
+		}
+		if b.mmapMode()&MmapModeGuardPage == 0 {
+			return mem, true
+		}
+		for offset := slotSize - pageSize; offset < size; offset += slotSize {
+			if err := unix.Mprotect(mem[offset:offset+pageSize], unix.PROT_NONE); err != nil {
+				b.fallbackMode(MmapModeGuardPage, err)
+				break
+			}
+		}
+		return mem, true
+	}
+}
+
+// protectPages changes protection of pages of the slot that contains data
+func (b *mmapAllocator) protectPages(data []byte, prot int) {
+	if b.protect < 1 {
+		return
+	}
+	slot, ok := b.slab.slot(data)
+	if ok != true {
+		// not allocated from mmap
+		return
+	}
+	if err := unix.Mprotect(slot[:b.protect], prot); err != nil {
+		b.fallbackMode(MmapModeProtectIdle, err)
+	}
+}
+
+func (b *MmapBytePool) preload(rate float64) {
+	if 0 < b.pool.Cap() {
+		preloadSize := int(float64(b.pool.Cap()) * rate)
+		for i := 0; i < preloadSize; i += 1 {
+			data := b.slab.alloc()
+			b.protectPages(data, unix.PROT_NONE)
+			if b.pool.Put(data[:b.bufSize]) != true {
+				b.slab.free(data)
+			}
 		}
 	}
 }
@@ -43,44 +268,154 @@ func (b *MmapBytePool) GetRef() *ByteRef {
 	return ref
 }
 
+func (b *MmapBytePool) GetRefContext(ctx context.Context) (*ByteRef, error) {
+	data, err := b.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := newByteRef(data, b)
+	ref.setFinalizer()
+	return ref, nil
+}
+
+func (b *mmapAllocator) create() []byte {
+	// create from slab
+	buf := b.slab.alloc()
+	return buf[:b.bufSize]
+}
+
+func (b *mmapAllocator) reset(data []byte) []byte {
+	return data[:b.bufSize]
+}
+
+// dispose releases trimmed buffer
+func (b *mmapAllocator) dispose(data []byte) {
+	b.forget(data)
+	b.slab.free(data)
+}
+
+// forget stops tracking provenance of discarded data
+func (b *mmapAllocator) forget(data []byte) {
+	if b.prov != nil {
+		b.prov.forget(data)
+	}
+}
+
 func (b *MmapBytePool) Get() []byte {
-	select {
-	case data := <-b.pool:
-		// reuse exists pool
-		return data[:b.bufSize]
-	default:
-		// create from mmap
-		buf, err := unix.Mmap(-1, 0, b.alignSize, mmapPerm, mmapFlag)
-		if err != nil {
-			buf = make([]byte, b.alignSize) // fallback
-		}
-		return buf[:b.bufSize]
+	data, _ := b.GetContext(context.Background())
+	return data
+}
+
+// GetContext waits until ctx is done if MaxOutstanding is reached
+func (b *MmapBytePool) GetContext(ctx context.Context) ([]byte, error) {
+	data, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
 	}
+	b.protectPages(data, mmapPerm)
+	if b.zero.onGet() {
+		zeroBytes(data)
+	}
+	if b.prov != nil {
+		b.prov.lend(data)
+	}
+	return data, nil
+}
+
+func (b *MmapBytePool) releaseLent(data []byte) {
+	b.pool.releaseLent(data)
 }
 
 func (b *MmapBytePool) Put(data []byte) bool {
-	if cap(data) != b.alignSize {
-		// discard
+	defer b.pool.release(data)
+
+	if cap(data) < b.bufCap {
+		// discard small buffer
+		b.pool.stats.discardTooSmall()
+		b.forget(data)
 		return false
 	}
+	if b.bufCap < cap(data) {
+		// discard, not allocated by this pool
+		b.pool.stats.discardTooBig()
+		b.forget(data)
+		return false
+	}
+
+	if b.prov != nil {
+		if b.prov.put(data, b.pool.stats) != true {
+			return false
+		}
+	}
+
+	// before store, data is reused by Get after stored
+	if b.zero.onPut() {
+		// also wipe discarded buffer, the slot is reused by slab
+		zeroBytes(data)
+	}
+	b.reclaim(data)
+	b.protectPages(data, unix.PROT_NONE)
 
-	select {
-	case b.pool <- data[:b.bufSize]:
-		// free capacity
+	if b.pool.store(data) {
 		return true
-	default:
-		// full capacity, discard it
-		unix.Munmap(data)
-		return false
 	}
+	b.forget(data)
+	// full capacity, discard it
+	b.slab.free(data)
+	return false
 }
 
 func (b *MmapBytePool) Len() int {
-	return len(b.pool)
+	return b.pool.Len()
 }
 
 func (b *MmapBytePool) Cap() int {
-	return cap(b.pool)
+	return b.pool.Cap()
+}
+
+// Slabs returns occupancy of each slab
+func (b *MmapBytePool) Slabs() []SlabInfo {
+	return b.slab.info()
+}
+
+// Alignment returns alignment of buffers, 0 if Alignment is not set
+func (b *MmapBytePool) Alignment() int {
+	return b.alignment
+}
+
+func (b *MmapBytePool) Stats() Stats {
+	return b.pool.Stats()
+}
+
+func (b *MmapBytePool) Trim() int {
+	return b.pool.Trim()
+}
+
+// Close releases pooled buffers, slab is unmapped when all buffers lent by Get are returned by Put
+func (b *MmapBytePool) Close() error {
+	if err := b.pool.Close(); err != nil {
+		return err
+	}
+	// pool no longer retains buffers
+	runtime.SetFinalizer(b, nil)
+	return nil
+}
+
+func (b *MmapBytePool) Drain(ctx context.Context) error {
+	return b.pool.Drain(ctx)
+}
+
+func (b *MmapBytePool) poolStats() *poolStats {
+	return b.pool.stats
+}
+
+func (b *MmapBytePool) leakDetector() *leakDetector {
+	return b.pool.leak
+}
+
+func (b *MmapBytePool) metricsLabels() []metricsLabel {
+	return bufSizeMetricsLabels(b.bufSize)
 }
 
 func NewMmapBytePool(poolSize, bufSize int, funcs ...optionFunc) *MmapBytePool {
@@ -90,10 +425,54 @@ func NewMmapBytePool(poolSize, bufSize int, funcs ...optionFunc) *MmapBytePool {
 	}
 
 	b := &MmapBytePool{
-		pool:      make(chan []byte, poolSize),
-		bufSize:   bufSize,
-		alignSize: defaultMmapAlign(bufSize),
+		mmapAllocator: &mmapAllocator{
+			bufSize:   bufSize,
+			alignSize: defaultMmapAlign(bufSize),
+			prov:      newProvenance(poolSize, opt),
+			mode:      uint32(newMmapMode(opt)),
+		},
+		zero: opt.zeroPolicy,
+	}
+	pageSize := unix.Getpagesize()
+	if pageSize < opt.alignment {
+		panic(unsupportedMmapAlignment)
+	}
+	if 1 < opt.alignment {
+		// slab is page aligned, slots of multiple of alignment keep it aligned
+		b.bufSize = alignUp(bufSize, opt.alignment)
+		b.alignSize = b.bufSize
+		b.alignment = opt.alignment
 	}
+	if b.Mode()&MmapModeHugePage != 0 {
+		b.hugePage = hugePageSize()
+		if b.hugePage < 1 {
+			b.fallbackMode(MmapModeHugePage, errHugePageUnavailable)
+		}
+	}
+	switch {
+	case b.Mode()&MmapModeGuardPage != 0:
+		// right-aligns buffer to guard page
+		dataPages := (b.bufSize + pageSize - 1) / pageSize * pageSize
+		slotSize := dataPages + pageSize
+		b.bufCap = b.bufSize
+		b.slab = newSlabArenaOffset(slotSize, dataPages-b.bufSize, b.bufSize, b.slabSize(opt.mmapSlabSize, slotSize), b.mmapGuarded(slotSize))
+		if b.Mode()&MmapModeProtectIdle != 0 {
+			b.protect = dataPages
+		}
+	case b.Mode()&MmapModeProtectIdle != 0:
+		// slot is page aligned, dont share the page with other buffers
+		slotSize := (b.alignSize + pageSize - 1) / pageSize * pageSize
+		b.bufCap = b.alignSize
+		b.slab = newSlabArenaOffset(slotSize, 0, b.alignSize, b.slabSize(opt.mmapSlabSize, slotSize), b.mmap)
+		b.protect = slotSize
+	default:
+		b.bufCap = b.alignSize
+		b.slab = newSlabArena(b.alignSize, b.slabSize(opt.mmapSlabSize, b.alignSize), b.mmap)
+	}
+	b.pool = newPool[[]byte](poolSize, b.create, opt)
+	b.pool.budget = newBudgetAccount(opt, b.alignSize)
+	b.pool.resetFunc = b.reset
+	b.pool.dispose = b.dispose
 
 	if opt.preload {
 		b.preload(opt.preloadRate)
@@ -107,10 +486,56 @@ func NewMmapBytePool(poolSize, bufSize int, funcs ...optionFunc) *MmapBytePool {
 func finalizeMmapBytePool(b *MmapBytePool) {
 	runtime.SetFinalizer(b, nil)
 
-	close(b.pool)
-	for data := range b.pool {
-		unix.Munmap(data)
+	// releases pooled buffers, slab in use is not unmapped
+	b.pool.Close()
+}
+
+func newMmapMode(opt *option) MmapMode {
+	mode := MmapMode(0)
+	if opt.mmapHugePage {
+		mode |= MmapModeHugePage
+	}
+	if opt.mmapPopulate {
+		mode |= MmapModePopulate
+	}
+	if opt.mmapLock {
+		mode |= MmapModeLock
+	}
+	if opt.mmapGuardPage {
+		mode |= MmapModeGuardPage
+	}
+	if opt.mmapProtectIdle {
+		mode |= MmapModeProtectIdle
+	}
+	if opt.zeroPolicy == ZeroSecure {
+		mode |= MmapModeLock | MmapModeDontDump
+	}
+	if opt.mmapFreeIdle {
+		if mmapSupportedModes&MmapModeFree != 0 {
+			mode |= MmapModeFree
+		} else {
+			mode |= MmapModeDontNeed
+		}
+	}
+	// unsupported platform
+	return mode & mmapSupportedModes
+}
+
+// mmapPages returns pages entirely in the buffer, madvise requires page aligned address
+func mmapPages(data []byte) []byte {
+	buf := data[:cap(data)]
+	if len(buf) < 1 {
+		return nil
+	}
+	pageSize := uintptr(unix.Getpagesize())
+	addr := uintptr(unsafe.Pointer(&buf[0]))
+	start := (addr + pageSize - 1) &^ (pageSize - 1)
+	end := (addr + uintptr(len(buf))) &^ (pageSize - 1)
+	if end <= start {
+		return nil
 	}
+	offset := int(start - addr)
+	return buf[offset : offset+int(end-start)]
 }
 
 func mmapAlign(size int, align int) int {
@@ -0,0 +1,29 @@
+//go:build aix
+// +build aix
+
+package bp
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// MAP_POPULATE, MADV_HUGEPAGE, MADV_DONTDUMP and MADV_FREE are not available
+	mmapPopulateFlag   = 0
+	madvHugePage       = 0
+	madvDontDump       = 0
+	madvFree           = 0
+	mmapSupportedModes = MmapModeLock | MmapModeDontNeed | MmapModeGuardPage | MmapModeProtectIdle
+)
+
+func fallocate(f *os.File, size int64) error {
+	// fallocate is linux only
+	return unix.ENOTSUP
+}
+
+func hugePageSize() int {
+	// transparent huge page is linux only
+	return 0
+}
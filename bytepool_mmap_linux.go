@@ -0,0 +1,37 @@
+//go:build linux
+// +build linux
+
+package bp
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	mmapPopulateFlag   = unix.MAP_POPULATE
+	madvHugePage       = unix.MADV_HUGEPAGE
+	madvDontDump       = unix.MADV_DONTDUMP
+	madvFree           = unix.MADV_FREE
+	mmapSupportedModes = MmapModeHugePage | MmapModePopulate | MmapModeLock | MmapModeFree | MmapModeDontNeed | MmapModeGuardPage | MmapModeProtectIdle | MmapModeDontDump
+)
+
+func fallocate(f *os.File, size int64) error {
+	return unix.Fallocate(int(f.Fd()), 0, 0, size)
+}
+
+// hugePageSize returns size of transparent huge page, 0 if THP is not available
+func hugePageSize() int {
+	data, err := os.ReadFile("/sys/kernel/mm/transparent_hugepage/hpage_pmd_size")
+	if err != nil {
+		return 0
+	}
+	size, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return size
+}
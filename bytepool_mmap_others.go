@@ -0,0 +1,29 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd netbsd openbsd solaris
+
+package bp
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// MAP_POPULATE, MADV_HUGEPAGE and MADV_DONTDUMP are linux only
+	mmapPopulateFlag   = 0
+	madvHugePage       = 0
+	madvDontDump       = 0
+	madvFree           = unix.MADV_FREE
+	mmapSupportedModes = MmapModeLock | MmapModeFree | MmapModeDontNeed | MmapModeGuardPage | MmapModeProtectIdle
+)
+
+func fallocate(f *os.File, size int64) error {
+	// fallocate is linux only
+	return unix.ENOTSUP
+}
+
+func hugePageSize() int {
+	// transparent huge page is linux only
+	return 0
+}
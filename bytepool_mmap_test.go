@@ -4,11 +4,16 @@
 package bp
 
 import (
+	"context"
 	"runtime"
+	"runtime/debug"
 	"strings"
 	"testing"
+	"time"
+	"unsafe"
 
 	"github.com/octu0/chanque"
+	"golang.org/x/sys/unix"
 )
 
 func BenchmarkMmapBytePool(b *testing.B) {
@@ -266,3 +271,578 @@ func TestMmapBytePoolPreload(t *testing.T) {
 		t.Errorf("preloaded buffer = %d", p.Len())
 	}
 }
+
+func TestMmapBytePoolProvenance(t *testing.T) {
+	p := NewMmapBytePool(10, 12, TrackProvenance(true), Preload(true))
+	d1 := p.Get()
+	d2 := p.Get()
+	if p.Put(d1) != true {
+		t.Errorf("preloaded buffer")
+	}
+	if p.Put(d1) {
+		t.Errorf("reject duplicate put")
+	}
+	if p.Put(d2) != true {
+		t.Errorf("lent buffer")
+	}
+	if p.Put(make([]byte, 12, p.alignSize)) {
+		t.Errorf("reject foreign put")
+	}
+	s := p.Stats()
+	if s.PutDiscardDuplicate != 1 || s.PutDiscardForeign != 1 {
+		t.Errorf("duplicate = %d foreign = %d", s.PutDiscardDuplicate, s.PutDiscardForeign)
+	}
+}
+
+func TestMmapBytePoolGetContext(t *testing.T) {
+	p := NewMmapBytePool(10, 12, MaxOutstanding(1))
+	r1, err := p.GetRefContext(context.Background())
+	if err != nil {
+		t.Fatalf("no error: %+v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.GetContext(ctx); err != context.Canceled {
+		t.Errorf("canceled: %+v", err)
+	}
+
+	r1.Release()
+	d2, err := p.GetContext(ctx)
+	if err != nil {
+		t.Errorf("released: %+v", err)
+	}
+	p.Put(d2)
+}
+
+func TestMmapBytePoolTrim(t *testing.T) {
+	p := NewMmapBytePool(10, 8, TrackProvenance(true))
+	data := make([][]byte, 0, 5)
+	for i := 0; i < 5; i += 1 {
+		data = append(data, p.Get())
+	}
+	for _, d := range data {
+		p.Put(d)
+	}
+	p.Trim()
+	if n := p.Trim(); n != 5 {
+		t.Errorf("munmap idle buffers = %d", n)
+	}
+	if p.Len() != 0 {
+		t.Errorf("len = %d", p.Len())
+	}
+
+	// trimmed buffer is forgotten
+	if p.Put(data[0]) {
+		t.Errorf("trimmed buffer is foreign")
+	}
+
+	mp := NewMultiMmapBytePool(
+		MultiMmapBytePoolSize(10, 8),
+		MultiMmapBytePoolSize(10, 100),
+	)
+	mp.Put(mp.Get(8))
+	mp.Put(mp.Get(100))
+	mp.Trim()
+	if n := mp.Trim(); n != 2 {
+		t.Errorf("trim each pool = %d", n)
+	}
+}
+
+func TestMmapBytePoolClose(t *testing.T) {
+	t.Run("close", func(tt *testing.T) {
+		p := NewMmapBytePool(10, 8)
+		lent := p.Get()
+		data := make([][]byte, 0, 5)
+		for i := 0; i < 5; i += 1 {
+			data = append(data, p.Get())
+		}
+		for _, d := range data {
+			p.Put(d)
+		}
+
+		if err := p.Close(); err != nil {
+			tt.Errorf("close: %+v", err)
+		}
+		if p.Len() != 0 {
+			tt.Errorf("len = %d", p.Len())
+		}
+		if n := len(p.Slabs()); n != 1 {
+			tt.Errorf("lent buffer keeps slab = %d", n)
+		}
+
+		// returned after Close
+		if p.Put(lent) {
+			tt.Errorf("put after close")
+		}
+		if n := len(p.Slabs()); n != 0 {
+			tt.Errorf("slab is not unmapped = %d", n)
+		}
+	})
+	t.Run("drain", func(tt *testing.T) {
+		p := NewMmapBytePool(10, 8)
+		r := p.GetRef()
+		go r.Release()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := p.Drain(ctx); err != nil {
+			tt.Errorf("drain: %+v", err)
+		}
+		p.Close()
+		if n := len(p.Slabs()); n != 0 {
+			tt.Errorf("slab is not unmapped = %d", n)
+		}
+	})
+	t.Run("multi", func(tt *testing.T) {
+		mp := NewMultiMmapBytePool(
+			MultiMmapBytePoolSize(10, 8),
+			MultiMmapBytePoolSize(10, 100),
+		)
+		r1 := mp.GetRef(8)
+		r2 := mp.GetRef(100)
+		r1.Release()
+		r2.Release()
+		if err := mp.Drain(context.Background()); err != nil {
+			tt.Errorf("drain: %+v", err)
+		}
+		if err := mp.Close(); err != nil {
+			tt.Errorf("close: %+v", err)
+		}
+		for size, slabs := range mp.Slabs() {
+			if len(slabs) != 0 {
+				tt.Errorf("%d slab is not unmapped = %d", size, len(slabs))
+			}
+		}
+		if err := mp.Close(); err != ErrPoolClosed {
+			tt.Errorf("close twice: %+v", err)
+		}
+	})
+	t.Run("finalizer", func(tt *testing.T) {
+		newSlab := func(funcs ...optionFunc) *slabArena {
+			p := NewMmapBytePool(10, 8, append(funcs, Preload(true))...)
+			if len(p.Slabs()) != 1 {
+				tt.Fatalf("preload maps slab")
+			}
+			return p.slab
+		}
+		testFinalizer := func(name string, slab *slabArena) {
+			for i := 0; i < 10; i += 1 {
+				runtime.GC()
+				time.Sleep(10 * time.Millisecond)
+				if len(slab.info()) == 0 {
+					return
+				}
+			}
+			tt.Errorf("%s: finalizer does not unmap slab = %d", name, len(slab.info()))
+		}
+		testFinalizer("default", newSlab())
+		testFinalizer("idletimeout", newSlab(IdleTimeout(time.Minute)))
+	})
+}
+
+func TestMmapBytePoolAlignment(t *testing.T) {
+	for _, align := range []int{512, 4096} {
+		p := NewMmapBytePool(10, 1000, Alignment(align))
+		if p.Alignment() != align {
+			t.Errorf("alignment = %d", p.Alignment())
+		}
+		data := make([][]byte, 0, 10)
+		for i := 0; i < 10; i += 1 {
+			d := p.Get()
+			if isAligned(d, align) != true {
+				t.Errorf("address is not aligned to %d", align)
+			}
+			if len(d)%align != 0 {
+				t.Errorf("len %d is not aligned to %d", len(d), align)
+			}
+			data = append(data, d)
+		}
+		for _, d := range data {
+			if p.Put(d) != true {
+				t.Errorf("aligned buffer is pooled")
+			}
+		}
+	}
+	t.Run("guardpage", func(tt *testing.T) {
+		p := NewMmapBytePool(10, 1000, Alignment(512), MmapGuardPage(true))
+		d := p.Get()
+		if isAligned(d, 512) != true || len(d) != 1024 {
+			tt.Errorf("right-aligned buffer is aligned: len = %d", len(d))
+		}
+	})
+	t.Run("largerthanpage", func(tt *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				tt.Errorf("alignment larger than page size panics")
+			}
+		}()
+		NewMmapBytePool(10, 1000, Alignment(unix.Getpagesize()*2))
+	})
+}
+
+func TestMmapBytePoolZeroing(t *testing.T) {
+	isZero := func(data []byte) bool {
+		for _, c := range data[:cap(data)] {
+			if c != 0 {
+				return false
+			}
+		}
+		return true
+	}
+	fill := func(data []byte) {
+		for i := range data {
+			data[i] = 0xff
+		}
+	}
+	t.Run("put", func(tt *testing.T) {
+		p := NewMmapBytePool(1, 8, Zeroing(ZeroOnPut))
+		d := p.Get()
+		fill(d)
+		p.Put(d)
+		if isZero(d) != true {
+			tt.Errorf("wiped on put")
+		}
+	})
+	t.Run("discarded", func(tt *testing.T) {
+		p := NewMmapBytePool(1, 8, Zeroing(ZeroOnPut))
+		d1, d2 := p.Get(), p.Get()
+		fill(d1)
+		fill(d2)
+		p.Put(d1)
+		// full, slot returns to slab
+		p.Put(d2)
+		if isZero(d2) != true {
+			tt.Errorf("wiped before returns to slab")
+		}
+	})
+	t.Run("get", func(tt *testing.T) {
+		p := NewMmapBytePool(1, 8, Zeroing(ZeroOnGet))
+		d := p.Get()
+		fill(d)
+		p.Put(d)
+		if isZero(p.Get()) != true {
+			tt.Errorf("wiped on get")
+		}
+	})
+	t.Run("secure", func(tt *testing.T) {
+		p := NewMmapBytePool(1, 8, Zeroing(ZeroSecure))
+		d := p.Get()
+		fill(d)
+		p.Put(d)
+		if isZero(d) != true {
+			tt.Errorf("wiped on put")
+		}
+		if mmapSupportedModes&MmapModeDontDump != 0 && p.Mode()&MmapModeDontDump == 0 {
+			tt.Errorf("mode = %s", p.Mode())
+		}
+	})
+}
+
+func TestMmapBytePoolMode(t *testing.T) {
+	t.Run("default", func(tt *testing.T) {
+		p := NewMmapBytePool(10, 8)
+		if p.Mode() != 0 {
+			tt.Errorf("no mode: %s", p.Mode())
+		}
+		if p.Mode().String() != "none" {
+			tt.Errorf("string = %s", p.Mode())
+		}
+	})
+	t.Run("requested", func(tt *testing.T) {
+		p := NewMmapBytePool(10, 4096, MmapHugePage(true), MmapPopulate(true), MmapLock(true), Preload(true))
+		d := p.Get()
+		d[0] = 1
+		p.Put(d)
+
+		// mode may fallback, it never has unrequested mode
+		if p.Mode()&^(MmapModeHugePage|MmapModePopulate|MmapModeLock) != 0 {
+			tt.Errorf("mode = %s", p.Mode())
+		}
+		if p.Mode()&^mmapSupportedModes != 0 {
+			tt.Errorf("supported mode = %s", p.Mode())
+		}
+	})
+	t.Run("hugepage", func(tt *testing.T) {
+		p := NewMmapBytePool(4, 4096, MmapHugePage(true))
+		p.Put(p.Get())
+		if p.Mode()&MmapModeHugePage == 0 {
+			if 0 < hugePageSize() {
+				tt.Errorf("THP is available: mode = %s", p.Mode())
+			}
+			return
+		}
+		slabs := p.Slabs()
+		if len(slabs) != 1 {
+			tt.Fatalf("slabs = %v", slabs)
+		}
+		if slabs[0].Slots*p.slab.slotSize < p.hugePage {
+			tt.Errorf("slab is smaller than huge page: %d slots", slabs[0].Slots)
+		}
+		if p.slab.slabs[0].base%uintptr(p.hugePage) != 0 {
+			tt.Errorf("slab is not aligned to huge page: %x", p.slab.slabs[0].base)
+		}
+	})
+	t.Run("fallback", func(tt *testing.T) {
+		p := NewMmapBytePool(10, 8, MmapLock(true))
+		p.mode = uint32(MmapModeLock | MmapModePopulate)
+		p.fallbackMode(MmapModeLock, unix.ENOMEM)
+		if p.Mode() != MmapModePopulate {
+			tt.Errorf("mode = %s", p.Mode())
+		}
+		if p.Mode().String() != "populate" {
+			tt.Errorf("string = %s", p.Mode())
+		}
+	})
+	t.Run("multi", func(tt *testing.T) {
+		mp := NewMultiMmapBytePool(
+			MultiMmapBytePoolSize(10, 8),
+			MultiMmapBytePoolSize(10, 100),
+			MultiMmapBytePoolOption(MmapLock(true)),
+		)
+		modes := mp.Modes()
+		if len(modes) != 2 {
+			tt.Errorf("modes = %v", modes)
+		}
+	})
+}
+
+func TestMmapModeString(t *testing.T) {
+	m := MmapModeHugePage | MmapModePopulate | MmapModeLock
+	if m.String() != "hugepage|populate|mlock" {
+		t.Errorf("string = %s", m)
+	}
+}
+
+func TestMmapBytePoolFreeIdle(t *testing.T) {
+	pageSize := unix.Getpagesize()
+	t.Run("reuse", func(tt *testing.T) {
+		p := NewMmapBytePool(10, pageSize*4, MmapFreeIdle(true))
+		if p.Mode()&(MmapModeFree|MmapModeDontNeed) == 0 {
+			tt.Errorf("mode = %s", p.Mode())
+		}
+		d := p.Get()
+		for i := range d {
+			d[i] = 1
+		}
+		if p.Put(d) != true {
+			tt.Errorf("pooled")
+		}
+
+		// no remap
+		d2 := p.Get()
+		if &d2[0] != &d[0] {
+			tt.Errorf("reuse same buffer")
+		}
+		d2[0] = 2
+		if d2[0] != 2 {
+			tt.Errorf("writable")
+		}
+	})
+	t.Run("dontneed", func(tt *testing.T) {
+		p := NewMmapBytePool(10, pageSize*4, MmapFreeIdle(true))
+		p.mode = uint32(MmapModeDontNeed)
+		d := p.Get()
+		for i := range d {
+			d[i] = 1
+		}
+		p.Put(d)
+		d = p.Get()
+		if runtime.GOOS == "linux" {
+			// anonymous private pages are zero-filled
+			if d[pageSize] != 0 {
+				tt.Errorf("pages are released")
+			}
+		}
+		if p.Mode() != MmapModeDontNeed {
+			tt.Errorf("mode = %s", p.Mode())
+		}
+	})
+	t.Run("disabled", func(tt *testing.T) {
+		p := NewMmapBytePool(10, pageSize*4)
+		d := p.Get()
+		d[pageSize] = 1
+		p.Put(d)
+		if d = p.Get(); d[pageSize] != 1 {
+			tt.Errorf("keep contents")
+		}
+	})
+}
+
+func TestMmapPages(t *testing.T) {
+	pageSize := unix.Getpagesize()
+	buf, err := unix.Mmap(-1, 0, pageSize*4, mmapPerm, mmapFlag)
+	if err != nil {
+		t.Fatalf("no error: %+v", err)
+	}
+	defer unix.Munmap(buf)
+
+	if p := mmapPages(buf[:0]); len(p) != pageSize*4 {
+		t.Errorf("aligned = %d", len(p))
+	}
+	if p := mmapPages(buf[1 : pageSize*3 : pageSize*3]); len(p) != pageSize*2 || &p[0] != &buf[pageSize] {
+		t.Errorf("pages inside buffer = %d", len(p))
+	}
+	if p := mmapPages(buf[1:pageSize:pageSize]); len(p) != 0 {
+		t.Errorf("smaller than page = %d", len(p))
+	}
+	if p := mmapPages(nil); p != nil {
+		t.Errorf("empty")
+	}
+}
+
+func TestMmapBytePoolGuardPage(t *testing.T) {
+	pageSize := unix.Getpagesize()
+	t.Run("rightalign", func(tt *testing.T) {
+		p := NewMmapBytePool(10, 100, MmapGuardPage(true), Preload(true))
+		if p.Mode()&MmapModeGuardPage == 0 {
+			tt.Fatalf("mode = %s", p.Mode())
+		}
+		d := p.Get()
+		if len(d) != 100 || cap(d) != 100 {
+			tt.Errorf("len = %d cap = %d", len(d), cap(d))
+		}
+		end := uintptr(unsafe.Pointer(&d[0])) + 100
+		if end%uintptr(pageSize) != 0 {
+			tt.Errorf("buffer ends at guard page")
+		}
+		if p.Put(d) != true {
+			tt.Errorf("pooled")
+		}
+		if p.Put(make([]byte, 100, p.alignSize)) {
+			tt.Errorf("cap is bufSize")
+		}
+	})
+	t.Run("overflow", func(tt *testing.T) {
+		p := NewMmapBytePool(10, 100, MmapGuardPage(true))
+		d := p.Get()
+		d[99] = 1
+
+		defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+		faulted := func() (fault bool) {
+			defer func() {
+				if r := recover(); r != nil {
+					fault = true
+				}
+			}()
+			over := unsafe.Slice(&d[0], 101)
+			over[100] = 1
+			return false
+		}()
+		if faulted != true {
+			tt.Errorf("overflow faults at guard page")
+		}
+	})
+	t.Run("slab", func(tt *testing.T) {
+		p := NewMmapBytePool(10, pageSize, MmapGuardPage(true), MmapSlabSize(pageSize*2*4))
+		data := make([][]byte, 0, 8)
+		for i := 0; i < 8; i += 1 {
+			data = append(data, p.Get())
+		}
+		if s := p.Slabs(); len(s) != 2 || s[0].Slots != 4 {
+			tt.Errorf("slot = data page + guard page: %+v", s)
+		}
+		for _, d := range data {
+			d[0] = 1
+			d[pageSize-1] = 1
+			p.Put(d)
+		}
+		p.Trim()
+		p.Trim()
+		if len(p.Slabs()) != 0 {
+			tt.Errorf("unmapped: %+v", p.Slabs())
+		}
+	})
+}
+
+func TestMmapBytePoolProtectIdle(t *testing.T) {
+	pageSize := unix.Getpagesize()
+	faulted := func(fn func()) (fault bool) {
+		defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+		defer func() {
+			if r := recover(); r != nil {
+				fault = true
+			}
+		}()
+		fn()
+		return false
+	}
+
+	t.Run("useafterput", func(tt *testing.T) {
+		p := NewMmapBytePool(10, 100, MmapProtectIdle(true))
+		if p.Mode()&MmapModeProtectIdle == 0 {
+			tt.Fatalf("mode = %s", p.Mode())
+		}
+		d := p.Get()
+		d[0] = 1
+		if cap(d) != p.alignSize {
+			tt.Errorf("cap = %d", cap(d))
+		}
+		p.Put(d)
+
+		if faulted(func() { d[0] = 2 }) != true {
+			tt.Errorf("write after put faults")
+		}
+		v := byte(0)
+		if faulted(func() { v = d[0] }) != true {
+			tt.Errorf("read after put faults")
+		}
+
+		if v != 0 {
+			tt.Errorf("not read")
+		}
+
+		d2 := p.Get()
+		if faulted(func() { d2[0] = 3 }) {
+			tt.Errorf("re-enabled in Get")
+		}
+		if d2[0] != 3 {
+			tt.Errorf("writable")
+		}
+	})
+	t.Run("ref", func(tt *testing.T) {
+		p := NewMmapBytePool(10, 100, MmapProtectIdle(true), Preload(true))
+		ref := p.GetRef()
+		ref.B[0] = 1
+		b := ref.B
+		ref.Release()
+		if faulted(func() { b[0] = 2 }) != true {
+			tt.Errorf("write after release faults")
+		}
+	})
+	t.Run("neighbour", func(tt *testing.T) {
+		p := NewMmapBytePool(10, 8, MmapProtectIdle(true))
+		d1 := p.Get()
+		d2 := p.Get()
+		p.Put(d1)
+		if faulted(func() { d2[0] = 1 }) {
+			tt.Errorf("buffer does not share page with other buffer")
+		}
+		if s := p.Slabs(); s[0].Slots != defaultMmapSlabSize/pageSize {
+			tt.Errorf("slot is page: %+v", s)
+		}
+	})
+	t.Run("guard", func(tt *testing.T) {
+		p := NewMmapBytePool(10, 100, MmapProtectIdle(true), MmapGuardPage(true))
+		d := p.Get()
+		d[99] = 1
+		p.Put(d)
+		if faulted(func() { d[99] = 2 }) != true {
+			tt.Errorf("write after put faults")
+		}
+		d = p.Get()
+		d[99] = 3
+	})
+	t.Run("multi", func(tt *testing.T) {
+		mp := NewMultiMmapBytePool(
+			MultiMmapBytePoolSize(10, 8),
+			MultiMmapBytePoolSize(10, 100),
+			MultiMmapBytePoolOption(MmapProtectIdle(true)),
+		)
+		d := mp.Get(100)
+		mp.Put(d)
+		if faulted(func() { d[0] = 1 }) != true {
+			tt.Errorf("write after put faults")
+		}
+	})
+}
@@ -0,0 +1,423 @@
+//go:build linux
+// +build linux
+
+package bp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	sharedMmapName    string = "bp"
+	sharedMessageSize int    = 16
+)
+
+const (
+	sharedMessageExport  uint32 = iota + 1 // index: number of slots, length: slot size, with memfd
+	sharedMessageSlot                      // index: slot, length: len of []byte
+	sharedMessageRelease                   // index: slot
+)
+
+var (
+	ErrSharedSlotNotOwned  = errors.New("slot is not owned by local process")
+	ErrSharedSlotNotLent   = errors.New("slot is not lent to this process")
+	ErrSharedInvalidExport = errors.New("invalid export message")
+)
+
+// compile check
+var (
+	_ Ref = (*SharedByteRef)(nil)
+)
+
+type sharedMessage struct {
+	typ    uint32
+	index  uint32
+	length uint64
+}
+
+func (m sharedMessage) encode() []byte {
+	buf := make([]byte, sharedMessageSize)
+	binary.LittleEndian.PutUint32(buf[0:4], m.typ)
+	binary.LittleEndian.PutUint32(buf[4:8], m.index)
+	binary.LittleEndian.PutUint64(buf[8:16], m.length)
+	return buf
+}
+
+func decodeSharedMessage(buf []byte) sharedMessage {
+	return sharedMessage{
+		typ:    binary.LittleEndian.Uint32(buf[0:4]),
+		index:  binary.LittleEndian.Uint32(buf[4:8]),
+		length: binary.LittleEndian.Uint64(buf[8:16]),
+	}
+}
+
+func readSharedMessage(conn *net.UnixConn) (sharedMessage, error) {
+	buf := make([]byte, sharedMessageSize)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return sharedMessage{}, err
+	}
+	return decodeSharedMessage(buf), nil
+}
+
+// SharedMmapBytePool is a pool of memfd slots that can be shared with another local process,
+// slot is handed over by index and returned to this pool when the receiver releases it.
+type SharedMmapBytePool struct {
+	pool *FileMmapBytePool
+}
+
+func (b *SharedMmapBytePool) GetRef() *ByteRef {
+	return b.pool.GetRef()
+}
+
+func (b *SharedMmapBytePool) GetRefContext(ctx context.Context) (*ByteRef, error) {
+	return b.pool.GetRefContext(ctx)
+}
+
+func (b *SharedMmapBytePool) Get() []byte {
+	return b.pool.Get()
+}
+
+func (b *SharedMmapBytePool) GetContext(ctx context.Context) ([]byte, error) {
+	return b.pool.GetContext(ctx)
+}
+
+func (b *SharedMmapBytePool) Put(data []byte) bool {
+	return b.pool.Put(data)
+}
+
+func (b *SharedMmapBytePool) Len() int {
+	return b.pool.Len()
+}
+
+func (b *SharedMmapBytePool) Cap() int {
+	return b.pool.Cap()
+}
+
+func (b *SharedMmapBytePool) Stats() Stats {
+	return b.pool.Stats()
+}
+
+// Close unmaps and closes memfd, the receivers keep their own mapping until they Close
+func (b *SharedMmapBytePool) Close() error {
+	return b.pool.Close()
+}
+
+// Drain waits until all Refs are released, slots handed over by Send are not waited.
+func (b *SharedMmapBytePool) Drain(ctx context.Context) error {
+	return b.pool.Drain(ctx)
+}
+
+func (b *SharedMmapBytePool) poolStats() *poolStats {
+	return b.pool.poolStats()
+}
+
+func (b *SharedMmapBytePool) leakDetector() *leakDetector {
+	return b.pool.leakDetector()
+}
+
+func (b *SharedMmapBytePool) metricsLabels() []metricsLabel {
+	return b.pool.metricsLabels()
+}
+
+// Export sends memfd to the process of conn, and starts receiving released slots from it.
+func (b *SharedMmapBytePool) Export(conn *net.UnixConn) (*SharedMmapExporter, error) {
+	msg := sharedMessage{
+		typ:    sharedMessageExport,
+		index:  uint32(len(b.pool.owners)),
+		length: uint64(b.pool.slotSize),
+	}
+	rights := unix.UnixRights(int(b.pool.file.Fd()))
+	if _, _, err := conn.WriteMsgUnix(msg.encode(), rights, nil); err != nil {
+		return nil, err
+	}
+
+	e := &SharedMmapExporter{
+		pool:  b.pool,
+		conn:  conn,
+		mutex: new(sync.Mutex),
+		sent:  make(map[int]struct{}),
+		done:  make(chan struct{}),
+	}
+	go e.serve()
+	return e, nil
+}
+
+// NewSharedMmapBytePool creates memfd of poolSize slots, IdleTimeout is ignored as FileMmapBytePool.
+func NewSharedMmapBytePool(poolSize, bufSize int, funcs ...optionFunc) (*SharedMmapBytePool, error) {
+	opt := newOption()
+	for _, fn := range funcs {
+		fn(opt)
+	}
+
+	fd, err := unix.MemfdCreate(sharedMmapName, unix.MFD_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	f := os.NewFile(uintptr(fd), sharedMmapName)
+	p, err := newFileMmapBytePool(f, poolSize, bufSize, opt)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	p.owners = make([]int32, p.Cap())
+
+	return &SharedMmapBytePool{
+		pool: p,
+	}, nil
+}
+
+// SharedMmapExporter hands over slots of SharedMmapBytePool to a process
+type SharedMmapExporter struct {
+	pool  *FileMmapBytePool
+	conn  *net.UnixConn
+	mutex *sync.Mutex
+	sent  map[int]struct{} // slots owned by remote
+	done  chan struct{}
+}
+
+// Send hands over B of ref to remote, ref is closed without returning to pool.
+func (e *SharedMmapExporter) Send(ref *ByteRef) error {
+	i, ok := e.pool.headSlotIndex(ref.B)
+	if ok != true {
+		return ErrSharedSlotNotOwned
+	}
+	if atomic.LoadInt32(&e.pool.owners[i]) != slotLocal {
+		return ErrSharedSlotNotOwned
+	}
+	if ref.detach() != true {
+		// already released
+		return ErrSharedSlotNotOwned
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.pool.handover(i) != true {
+		// ref is closed, return the slot to pool
+		e.pool.Put(ref.B)
+		return ErrSharedSlotNotOwned
+	}
+	e.sent[i] = struct{}{}
+
+	msg := sharedMessage{
+		typ:    sharedMessageSlot,
+		index:  uint32(i),
+		length: uint64(len(ref.B)),
+	}
+	if _, err := e.conn.Write(msg.encode()); err != nil {
+		// remote never received it
+		delete(e.sent, i)
+		e.pool.takeback(i)
+		return err
+	}
+	return nil
+}
+
+func (e *SharedMmapExporter) serve() {
+	defer close(e.done)
+
+	for {
+		msg, err := readSharedMessage(e.conn)
+		if err != nil {
+			// remote is closed or dead, it never releases the slots
+			e.takebackAll()
+			return
+		}
+		if msg.typ != sharedMessageRelease {
+			continue
+		}
+		e.takeback(int(msg.index))
+	}
+}
+
+func (e *SharedMmapExporter) takeback(i int) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if _, ok := e.sent[i]; ok != true {
+		// not sent by this exporter
+		return
+	}
+	delete(e.sent, i)
+	e.pool.takeback(i)
+}
+
+// takebackAll takes back every slot sent to remote
+func (e *SharedMmapExporter) takebackAll() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for i := range e.sent {
+		delete(e.sent, i)
+		e.pool.takeback(i)
+	}
+}
+
+// Close closes conn, and takes back the slots that remote does not release.
+func (e *SharedMmapExporter) Close() error {
+	err := e.conn.Close()
+	<-e.done
+
+	e.takebackAll()
+	return err
+}
+
+// SharedMmapImporter maps memfd of SharedMmapBytePool exported by another process
+type SharedMmapImporter struct {
+	conn     *net.UnixConn
+	mem      []byte
+	slotSize int
+	mutex    *sync.Mutex
+	held     map[int]struct{}
+}
+
+// Receive waits for a slot handed over by exporter
+func (i *SharedMmapImporter) Receive() (*SharedByteRef, error) {
+	for {
+		msg, err := readSharedMessage(i.conn)
+		if err != nil {
+			return nil, err
+		}
+		if msg.typ != sharedMessageSlot {
+			continue
+		}
+
+		index := int(msg.index)
+		offset := index * i.slotSize
+		if len(i.mem) < offset+i.slotSize || uint64(i.slotSize) < msg.length {
+			return nil, ErrSharedSlotNotLent
+		}
+
+		i.mutex.Lock()
+		i.held[index] = struct{}{}
+		i.mutex.Unlock()
+
+		ref := &SharedByteRef{
+			B:        i.mem[offset : offset+int(msg.length) : offset+i.slotSize],
+			Index:    index,
+			importer: i,
+			closed:   refInit,
+		}
+		ref.setFinalizer()
+		return ref, nil
+	}
+}
+
+func (i *SharedMmapImporter) release(index int) error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if _, ok := i.held[index]; ok != true {
+		return ErrSharedSlotNotLent
+	}
+	delete(i.held, index)
+
+	msg := sharedMessage{
+		typ:   sharedMessageRelease,
+		index: uint32(index),
+	}
+	_, err := i.conn.Write(msg.encode())
+	return err
+}
+
+// Close unmaps memfd and closes conn, exporter takes back the slots that are not released.
+func (i *SharedMmapImporter) Close() error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if err := unix.Munmap(i.mem); err != nil {
+		return err
+	}
+	return i.conn.Close()
+}
+
+// ImportSharedMmapBytePool receives memfd sent by SharedMmapBytePool.Export and maps it.
+func ImportSharedMmapBytePool(conn *net.UnixConn) (*SharedMmapImporter, error) {
+	buf := make([]byte, sharedMessageSize)
+	oob := make([]byte, unix.CmsgSpace(4))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return nil, err
+	}
+	if n != sharedMessageSize {
+		return nil, ErrSharedInvalidExport
+	}
+	msg := decodeSharedMessage(buf)
+	if msg.typ != sharedMessageExport {
+		return nil, ErrSharedInvalidExport
+	}
+
+	cmsgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, err
+	}
+	if len(cmsgs) < 1 {
+		return nil, ErrSharedInvalidExport
+	}
+	fds, err := unix.ParseUnixRights(&cmsgs[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(fds) < 1 {
+		return nil, ErrSharedInvalidExport
+	}
+	// mapping is kept after close
+	defer unix.Close(fds[0])
+
+	slotSize := int(msg.length)
+	mem, err := unix.Mmap(fds[0], 0, int(msg.index)*slotSize, mmapPerm, fileMmapFlag)
+	if err != nil {
+		return nil, err
+	}
+	return &SharedMmapImporter{
+		conn:     conn,
+		mem:      mem,
+		slotSize: slotSize,
+		mutex:    new(sync.Mutex),
+		held:     make(map[int]struct{}),
+	}, nil
+}
+
+// SharedByteRef is a slot received by SharedMmapImporter, Release returns it to the originating pool.
+type SharedByteRef struct {
+	B        []byte
+	Index    int
+	importer *SharedMmapImporter
+	closed   int32
+}
+
+func (b *SharedByteRef) Bytes() []byte {
+	return b.B
+}
+
+func (b *SharedByteRef) isClosed() bool {
+	return atomic.LoadInt32(&b.closed) == refClosed
+}
+
+func (b *SharedByteRef) setFinalizer() {
+	runtime.SetFinalizer(b, finalizeRef)
+}
+
+func (b *SharedByteRef) finalize() {
+	b.release()
+}
+
+func (b *SharedByteRef) Release() {
+	b.release()
+}
+
+func (b *SharedByteRef) release() {
+	if atomic.CompareAndSwapInt32(&b.closed, refInit, refClosed) {
+		b.importer.release(b.Index)
+	}
+}
@@ -0,0 +1,198 @@
+//go:build linux
+// +build linux
+
+package bp
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func testUnixConnPair(t *testing.T) (*net.UnixConn, *net.UnixConn) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatalf("no error: %+v", err)
+	}
+	conns := make([]*net.UnixConn, 2)
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "socketpair")
+		c, err := net.FileConn(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("no error: %+v", err)
+		}
+		conns[i] = c.(*net.UnixConn)
+	}
+	return conns[0], conns[1]
+}
+
+func TestSharedMmapBytePool(t *testing.T) {
+	t.Run("handover", func(tt *testing.T) {
+		p, err := NewSharedMmapBytePool(4, 100)
+		if err != nil {
+			tt.Fatalf("no error: %+v", err)
+		}
+		defer p.Close()
+
+		c1, c2 := testUnixConnPair(tt)
+		exporter, err := p.Export(c1)
+		if err != nil {
+			tt.Fatalf("no error: %+v", err)
+		}
+		defer exporter.Close()
+
+		importer, err := ImportSharedMmapBytePool(c2)
+		if err != nil {
+			tt.Fatalf("no error: %+v", err)
+		}
+		defer importer.Close()
+
+		ref := p.GetRef()
+		copy(ref.B, []byte("frame"))
+		ref.B = ref.B[:5]
+		if err := exporter.Send(ref); err != nil {
+			tt.Fatalf("no error: %+v", err)
+		}
+		if p.Len() != 3 {
+			tt.Errorf("owned by remote: len = %d", p.Len())
+		}
+
+		r, err := importer.Receive()
+		if err != nil {
+			tt.Fatalf("no error: %+v", err)
+		}
+		if string(r.B) != "frame" {
+			tt.Errorf("shared memory: %q", r.B)
+		}
+
+		// receiver writes, sender reads
+		r.B[0] = 'F'
+		if ref.B[0] != 'F' {
+			tt.Errorf("same memory")
+		}
+
+		// owned by remote, local Put is rejected
+		if p.Put(ref.B[:100]) {
+			tt.Errorf("not owned by local")
+		}
+
+		r.Release()
+		deadline := time.Now().Add(5 * time.Second)
+		for p.Len() != 4 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if p.Len() != 4 {
+			tt.Errorf("returned to originating pool: len = %d", p.Len())
+		}
+		if s := p.Stats(); s.RefOutstanding != 0 {
+			tt.Errorf("outstanding = %d", s.RefOutstanding)
+		}
+	})
+	t.Run("send", func(tt *testing.T) {
+		p, err := NewSharedMmapBytePool(1, 100)
+		if err != nil {
+			tt.Fatalf("no error: %+v", err)
+		}
+		defer p.Close()
+
+		c1, c2 := testUnixConnPair(tt)
+		defer c2.Close()
+		exporter, err := p.Export(c1)
+		if err != nil {
+			tt.Fatalf("no error: %+v", err)
+		}
+
+		r1 := p.GetRef()
+		r2 := p.GetRef() // heap
+		if err := exporter.Send(r2); err != ErrSharedSlotNotOwned {
+			tt.Errorf("heap is not slot: %+v", err)
+		}
+		r1.Release()
+		if err := exporter.Send(r1); err != ErrSharedSlotNotOwned {
+			tt.Errorf("released: %+v", err)
+		}
+
+		r3 := p.GetRef()
+		if err := exporter.Send(r3); err != nil {
+			tt.Errorf("no error: %+v", err)
+		}
+		if p.Len() != 0 {
+			tt.Errorf("len = %d", p.Len())
+		}
+		// remote has gone
+		exporter.Close()
+		if p.Len() != 1 {
+			tt.Errorf("take back not released slot: len = %d", p.Len())
+		}
+	})
+	t.Run("peerclosed", func(tt *testing.T) {
+		p, err := NewSharedMmapBytePool(2, 100)
+		if err != nil {
+			tt.Fatalf("no error: %+v", err)
+		}
+		defer p.Close()
+
+		c1, c2 := testUnixConnPair(tt)
+		exporter, _ := p.Export(c1)
+		defer exporter.Close()
+		importer, err := ImportSharedMmapBytePool(c2)
+		if err != nil {
+			tt.Fatalf("no error: %+v", err)
+		}
+
+		if err := exporter.Send(p.GetRef()); err != nil {
+			tt.Fatalf("no error: %+v", err)
+		}
+		if p.Len() != 1 {
+			tt.Errorf("owned by remote: len = %d", p.Len())
+		}
+		importer.Close()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for p.Len() != 2 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if p.Len() != 2 {
+			tt.Errorf("take back slots of closed remote: len = %d", p.Len())
+		}
+	})
+	t.Run("release", func(tt *testing.T) {
+		p, err := NewSharedMmapBytePool(2, 100)
+		if err != nil {
+			tt.Fatalf("no error: %+v", err)
+		}
+		defer p.Close()
+
+		c1, c2 := testUnixConnPair(tt)
+		exporter, _ := p.Export(c1)
+		defer exporter.Close()
+		importer, err := ImportSharedMmapBytePool(c2)
+		if err != nil {
+			tt.Fatalf("no error: %+v", err)
+		}
+		defer importer.Close()
+
+		exporter.Send(p.GetRef())
+		r, _ := importer.Receive()
+		if err := importer.release(r.Index); err != nil {
+			tt.Errorf("no error: %+v", err)
+		}
+		if err := importer.release(r.Index); err != ErrSharedSlotNotLent {
+			tt.Errorf("already released: %+v", err)
+		}
+	})
+	t.Run("invalid", func(tt *testing.T) {
+		c1, c2 := testUnixConnPair(tt)
+		defer c1.Close()
+		defer c2.Close()
+
+		c1.Write(sharedMessage{typ: sharedMessageSlot}.encode())
+		if _, err := ImportSharedMmapBytePool(c2); err != ErrSharedInvalidExport {
+			tt.Errorf("not export: %+v", err)
+		}
+	})
+}
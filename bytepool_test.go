@@ -1,10 +1,12 @@
 package bp
 
 import (
+	"context"
 	"runtime"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/octu0/chanque"
 )
@@ -285,3 +287,137 @@ func TestBytePoolPreload(t *testing.T) {
 		t.Errorf("preloaded buffer = %d", p.Len())
 	}
 }
+
+func TestBytePoolGetContext(t *testing.T) {
+	p := NewBytePool(10, 8, MaxOutstanding(2))
+	d1, err := p.GetContext(context.Background())
+	if err != nil {
+		t.Fatalf("no error: %+v", err)
+	}
+	r2, err := p.GetRefContext(context.Background())
+	if err != nil {
+		t.Fatalf("no error: %+v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.GetContext(ctx); err != context.Canceled {
+		t.Errorf("canceled: %+v", err)
+	}
+
+	// discarded buffer also releases outstanding
+	p.Put(d1[:4:4])
+	if _, err := p.GetContext(ctx); err != nil {
+		t.Errorf("released by put: %+v", err)
+	}
+
+	r2.Release()
+	if _, err := p.GetRefContext(ctx); err != nil {
+		t.Errorf("released by ref: %+v", err)
+	}
+
+	// buffer not obtained from pool does not release outstanding
+	p.Put(make([]byte, 8))
+	p.Put(make([]byte, 100))
+	if _, err := p.GetContext(ctx); err != context.Canceled {
+		t.Errorf("still outstanding: %+v", err)
+	}
+}
+
+func TestBytePoolRefOutstanding(t *testing.T) {
+	p := NewBytePool(10, 8, MaxOutstanding(1))
+	for i := 0; i < 2; i += 1 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		r1, err := p.GetRefContext(ctx)
+		cancel()
+		if err != nil {
+			t.Fatalf("released by previous ref: %+v", err)
+		}
+		switch i {
+		case 0:
+			r1.B = append(r1.B, make([]byte, 100)...) // grown, discarded as too big
+		case 1:
+			r1.B = r1.B[4:] // resliced from offset
+		}
+		r1.Release()
+	}
+	if s := p.Stats(); s.PutDiscardTooBig != 1 {
+		t.Errorf("too big = %d", s.PutDiscardTooBig)
+	}
+}
+
+func TestBytePoolAlignment(t *testing.T) {
+	for _, align := range []int{512, 4096} {
+		p := NewBytePool(10, 1000, Alignment(align))
+		if p.Alignment() != align {
+			t.Errorf("alignment = %d", p.Alignment())
+		}
+		for i := 0; i < 10; i += 1 {
+			data := p.Get()
+			if isAligned(data, align) != true {
+				t.Errorf("address is not aligned to %d", align)
+			}
+			if len(data)%align != 0 {
+				t.Errorf("len %d is not aligned to %d", len(data), align)
+			}
+		}
+		if p.Put(make([]byte, alignUp(1000, align)+1)[1:]) {
+			t.Errorf("unaligned buffer is discarded")
+		}
+	}
+}
+
+func TestBytePoolZeroing(t *testing.T) {
+	isZero := func(data []byte) bool {
+		for _, c := range data[:cap(data)] {
+			if c != 0 {
+				return false
+			}
+		}
+		return true
+	}
+	fill := func(data []byte) {
+		for i := range data {
+			data[i] = 0xff
+		}
+	}
+	t.Run("none", func(tt *testing.T) {
+		p := NewBytePool(1, 8, Zeroing(ZeroNone))
+		d := p.Get()
+		fill(d)
+		p.Put(d)
+		if isZero(p.Get()) {
+			tt.Errorf("contents are kept")
+		}
+	})
+	t.Run("put", func(tt *testing.T) {
+		p := NewBytePool(1, 8, Zeroing(ZeroOnPut))
+		d := p.Get()
+		fill(d)
+		p.Put(d)
+		if isZero(d) != true {
+			tt.Errorf("wiped on put")
+		}
+	})
+	t.Run("get", func(tt *testing.T) {
+		p := NewBytePool(1, 8, Zeroing(ZeroOnGet))
+		d := p.Get()
+		fill(d)
+		p.Put(d)
+		if isZero(d) {
+			tt.Errorf("not wiped until get")
+		}
+		if isZero(p.Get()) != true {
+			tt.Errorf("wiped on get")
+		}
+	})
+	t.Run("secure", func(tt *testing.T) {
+		p := NewBytePool(1, 8, Zeroing(ZeroSecure))
+		d := p.Get()
+		fill(d)
+		p.Put(d)
+		if isZero(d) != true {
+			tt.Errorf("wiped on put")
+		}
+	})
+}
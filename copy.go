@@ -2,6 +2,7 @@ package bp
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 )
@@ -55,6 +56,26 @@ func (c *CopyIOPool) Cap() int {
 	return c.pool.Cap()
 }
 
+func (c *CopyIOPool) Stats() Stats {
+	return c.pool.Stats()
+}
+
+func (c *CopyIOPool) Trim() int {
+	return c.pool.Trim()
+}
+
+func (c *CopyIOPool) Close() error {
+	return c.pool.Close()
+}
+
+func (c *CopyIOPool) Drain(ctx context.Context) error {
+	return c.pool.Drain(ctx)
+}
+
+func (c *CopyIOPool) metricsLabels() []metricsLabel {
+	return c.pool.metricsLabels()
+}
+
 func NewCopyIOPool(poolSize int, bufSize int, funcs ...optionFunc) *CopyIOPool {
 	return &CopyIOPool{
 		pool: NewBytePool(poolSize, bufSize, funcs...),
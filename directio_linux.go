@@ -0,0 +1,238 @@
+//go:build linux
+// +build linux
+
+package bp
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	ErrDirectIONotAligned = errors.New("buffer of pool is not aligned for direct I/O")
+)
+
+// compile check
+var (
+	_ io.WriteCloser  = (*DirectWriter)(nil)
+	_ io.ReadCloser   = (*DirectReader)(nil)
+	_ AlignedBytePool = (*BytePool)(nil)
+	_ AlignedBytePool = (*MmapBytePool)(nil)
+)
+
+// AlignedBytePool is a pool of aligned buffers, BytePool or MmapBytePool created with Alignment
+type AlignedBytePool interface {
+	Get() []byte
+	Put([]byte) bool
+	Alignment() int
+}
+
+// DirectWriter writes file with O_DIRECT through an aligned buffer of pool,
+// the unaligned tail is padded on Close and the file is truncated to written size.
+type DirectWriter struct {
+	file   *os.File
+	pool   AlignedBytePool
+	buf    []byte
+	n      int   // buffered bytes
+	offset int64 // file offset of buf
+	direct bool
+}
+
+func (w *DirectWriter) Write(p []byte) (int, error) {
+	if w.buf == nil {
+		return 0, os.ErrClosed
+	}
+
+	written := 0
+	for 0 < len(p) {
+		c := copy(w.buf[w.n:], p)
+		w.n += c
+		written += c
+		p = p[c:]
+
+		if w.n == len(w.buf) {
+			if err := w.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (w *DirectWriter) flush() error {
+	if _, err := w.file.WriteAt(w.buf, w.offset); err != nil {
+		return err
+	}
+	w.offset += int64(len(w.buf))
+	w.n = 0
+	return nil
+}
+
+// Direct returns false if filesystem does not support O_DIRECT and file is written through page cache
+func (w *DirectWriter) Direct() bool {
+	return w.direct
+}
+
+// Close writes buffered tail and closes file
+func (w *DirectWriter) Close() error {
+	if w.buf == nil {
+		return os.ErrClosed
+	}
+	defer w.release()
+
+	if 0 < w.n {
+		size := w.offset + int64(w.n)
+		tail := alignUp(w.n, w.pool.Alignment())
+		for i := w.n; i < tail; i += 1 {
+			// zero padding, truncated later
+			w.buf[i] = 0
+		}
+		if _, err := w.file.WriteAt(w.buf[:tail], w.offset); err != nil {
+			w.file.Close()
+			return err
+		}
+		if err := w.file.Truncate(size); err != nil {
+			w.file.Close()
+			return err
+		}
+	}
+	return w.file.Close()
+}
+
+func (w *DirectWriter) release() {
+	w.pool.Put(w.buf)
+	w.buf = nil
+}
+
+// OpenDirectWriter creates or truncates name and opens it with O_DIRECT,
+// fallback to buffered I/O if filesystem does not support O_DIRECT (e.g. tmpfs).
+func OpenDirectWriter(name string, perm os.FileMode, pool AlignedBytePool) (*DirectWriter, error) {
+	buf, err := getDirectBuffer(pool)
+	if err != nil {
+		return nil, err
+	}
+	f, direct, err := openDirect(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		pool.Put(buf)
+		return nil, err
+	}
+	return &DirectWriter{
+		file:   f,
+		pool:   pool,
+		buf:    buf,
+		direct: direct,
+	}, nil
+}
+
+// DirectReader reads file with O_DIRECT through an aligned buffer of pool
+type DirectReader struct {
+	file   *os.File
+	pool   AlignedBytePool
+	buf    []byte
+	r, n   int   // read and filled position of buf
+	offset int64 // file offset of next fill
+	eof    bool
+	direct bool
+}
+
+func (r *DirectReader) Read(p []byte) (int, error) {
+	if r.buf == nil {
+		return 0, os.ErrClosed
+	}
+
+	if r.r == r.n {
+		if r.eof {
+			return 0, io.EOF
+		}
+		if err := r.fill(); err != nil {
+			return 0, err
+		}
+		if r.n < 1 {
+			return 0, io.EOF
+		}
+	}
+	c := copy(p, r.buf[r.r:r.n])
+	r.r += c
+	return c, nil
+}
+
+func (r *DirectReader) fill() error {
+	// single pread, next offset of short read (unaligned tail) is not allowed with O_DIRECT
+	n, err := unix.Pread(int(r.file.Fd()), r.buf, r.offset)
+	if err != nil {
+		return err
+	}
+	if n < len(r.buf) {
+		r.eof = true
+	}
+	r.offset += int64(n)
+	r.r = 0
+	r.n = n
+	return nil
+}
+
+// Direct returns false if filesystem does not support O_DIRECT and file is read through page cache
+func (r *DirectReader) Direct() bool {
+	return r.direct
+}
+
+func (r *DirectReader) Close() error {
+	if r.buf == nil {
+		return os.ErrClosed
+	}
+	r.pool.Put(r.buf)
+	r.buf = nil
+	return r.file.Close()
+}
+
+// OpenDirectReader opens name with O_DIRECT,
+// fallback to buffered I/O if filesystem does not support O_DIRECT (e.g. tmpfs).
+func OpenDirectReader(name string, pool AlignedBytePool) (*DirectReader, error) {
+	buf, err := getDirectBuffer(pool)
+	if err != nil {
+		return nil, err
+	}
+	f, direct, err := openDirect(name, os.O_RDONLY, 0)
+	if err != nil {
+		pool.Put(buf)
+		return nil, err
+	}
+	return &DirectReader{
+		file:   f,
+		pool:   pool,
+		buf:    buf,
+		direct: direct,
+	}, nil
+}
+
+func getDirectBuffer(pool AlignedBytePool) ([]byte, error) {
+	align := pool.Alignment()
+	if align < 2 {
+		return nil, ErrDirectIONotAligned
+	}
+	buf := pool.Get()
+	if len(buf) < 1 || len(buf)%align != 0 || isAligned(buf, align) != true {
+		pool.Put(buf)
+		return nil, ErrDirectIONotAligned
+	}
+	return buf, nil
+}
+
+func openDirect(name string, flag int, perm os.FileMode) (*os.File, bool, error) {
+	f, err := os.OpenFile(name, flag|unix.O_DIRECT, perm)
+	if err == nil {
+		return f, true, nil
+	}
+	if errors.Is(err, unix.EINVAL) != true {
+		return nil, false, err
+	}
+	// O_DIRECT is not supported by filesystem
+	f, err = os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, false, err
+	}
+	return f, false, nil
+}
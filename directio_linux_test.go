@@ -0,0 +1,87 @@
+//go:build linux
+// +build linux
+
+package bp
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirectIO(t *testing.T) {
+	testReadWrite := func(tt *testing.T, pool AlignedBytePool, size int) {
+		name := filepath.Join(tt.TempDir(), "direct")
+		data := bytes.Repeat([]byte("0123456789abcdef"), size/16+1)[:size]
+
+		w, err := OpenDirectWriter(name, 0600, pool)
+		if err != nil {
+			tt.Fatalf("open writer: %+v", err)
+		}
+		tt.Logf("direct write: %v", w.Direct())
+		// write by odd chunk
+		for i := 0; i < len(data); i += 1000 {
+			end := i + 1000
+			if len(data) < end {
+				end = len(data)
+			}
+			if _, err := w.Write(data[i:end]); err != nil {
+				tt.Fatalf("write: %+v", err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			tt.Fatalf("close writer: %+v", err)
+		}
+		if err := w.Close(); err != os.ErrClosed {
+			tt.Errorf("close twice: %+v", err)
+		}
+
+		info, err := os.Stat(name)
+		if err != nil {
+			tt.Fatalf("stat: %+v", err)
+		}
+		if info.Size() != int64(size) {
+			tt.Errorf("unaligned tail is truncated: size = %d", info.Size())
+		}
+
+		r, err := OpenDirectReader(name, pool)
+		if err != nil {
+			tt.Fatalf("open reader: %+v", err)
+		}
+		defer r.Close()
+
+		out, err := io.ReadAll(r)
+		if err != nil {
+			tt.Fatalf("read: %+v", err)
+		}
+		if bytes.Equal(out, data) != true {
+			tt.Errorf("read data mismatch: len = %d", len(out))
+		}
+	}
+
+	t.Run("bytepool", func(tt *testing.T) {
+		pool := NewBytePool(2, 4096, Alignment(4096))
+		testReadWrite(tt, pool, 10000)
+		testReadWrite(tt, pool, 8192)
+	})
+	t.Run("mmapbytepool", func(tt *testing.T) {
+		pool := NewMmapBytePool(2, 4096, Alignment(4096))
+		testReadWrite(tt, pool, 10000)
+		testReadWrite(tt, pool, 100)
+	})
+	t.Run("empty", func(tt *testing.T) {
+		pool := NewBytePool(2, 512, Alignment(512))
+		testReadWrite(tt, pool, 0)
+	})
+	t.Run("notaligned", func(tt *testing.T) {
+		name := filepath.Join(tt.TempDir(), "direct")
+		if _, err := OpenDirectWriter(name, 0600, NewBytePool(2, 4096)); err != ErrDirectIONotAligned {
+			tt.Errorf("pool without Alignment: %+v", err)
+		}
+		if _, err := OpenDirectReader(name, NewMmapBytePool(2, 4096)); err != ErrDirectIONotAligned {
+			tt.Errorf("pool without Alignment: %+v", err)
+		}
+	})
+}
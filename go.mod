@@ -1,6 +1,6 @@
 module github.com/octu0/bp
 
-go 1.17
+go 1.18
 
 require (
 	github.com/octu0/chanque v1.0.15
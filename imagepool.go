@@ -1,101 +1,171 @@
 package bp
 
 import (
+	"context"
 	"image"
+	"image/color"
 )
 
-const (
-	notyetSupportedSampleRate string = "not yet supported sample rate"
-)
-
-type ImageRGBAPool struct {
-	pool   chan []byte
-	rect   image.Rectangle
-	width  int
-	height int
-	stride int
-	length int
+// pixelPool is pool of pixels of image which has bytesPerPixel, image pools build image on it
+type pixelPool struct {
+	pool          *Pool[[]byte]
+	rect          image.Rectangle
+	width         int
+	height        int
+	stride        int
+	length        int
+	bytesPerPixel int
+	align         int // row and plane alignment, 0 if not aligned
 }
 
-func (b *ImageRGBAPool) init(rect image.Rectangle) {
+// initPixel initializes size of image which has bytesPerPixel
+func (b *pixelPool) initPixel(rect image.Rectangle, bytesPerPixel int) {
 	b.rect = rect
 	b.width = rect.Dx()
 	b.height = rect.Dy()
-	b.stride = imageRGBAStride(rect)
-	b.length = rect.Dx() * rect.Dy() * 4
+	b.bytesPerPixel = bytesPerPixel
+	b.stride = imageStride(rect, bytesPerPixel, b.align)
+	b.length = b.stride * rect.Dy()
 }
 
-func (b *ImageRGBAPool) createImageRGBARef(pix []byte, pool *ImageRGBAPool) *ImageRGBARef {
-	ref := newImageRGBARef(pix, &image.RGBA{
-		Pix:    pix,
-		Stride: b.stride,
-		Rect:   b.rect,
-	}, pool)
-	ref.setFinalizer()
-	return ref
-}
+func (b *pixelPool) setup(poolSize int, rect image.Rectangle, bytesPerPixel int, funcs []optionFunc) {
+	opt := newOption()
+	for _, fn := range funcs {
+		fn(opt)
+	}
 
-func (b *ImageRGBAPool) GetRef() *ImageRGBARef {
-	var pix []byte
-	select {
-	case pix = <-b.pool:
-		// reuse exists pool
-	default:
-		// create []byte
-		pix = make([]byte, b.length)
+	b.align = opt.strideAlignment
+	b.initPixel(rect, bytesPerPixel)
+	b.pool = newPool[[]byte](poolSize, b.create, opt)
+	b.pool.budget = newBudgetAccount(opt, b.length)
+	b.pool.resetFunc = b.reset
+
+	if opt.preload {
+		b.pool.preload(opt.preloadRate)
 	}
-	return b.createImageRGBARef(pix, b)
 }
 
-func (b *ImageRGBAPool) preload(rate float64) {
-	if 0 < cap(b.pool) {
-		preloadSize := int(float64(cap(b.pool)) * rate)
-		for i := 0; i < preloadSize; i += 1 {
-			b.Put(make([]byte, b.length))
-		}
+// strideRect returns stride of image of r
+func (b *pixelPool) strideRect(r image.Rectangle) int {
+	return imageStride(r, b.bytesPerPixel, b.align)
+}
+
+// fits reports whether image of r can be built on pixels of this pool
+func (b *pixelPool) fits(r image.Rectangle) bool {
+	if r.Empty() {
+		return false
 	}
+	return rectIn(b.rect, r)
+}
+
+func (b *pixelPool) create() []byte {
+	// create []byte
+	return alignedBytes(b.length, b.align)
+}
+
+// createRect creates []byte for image of r
+func (b *pixelPool) createRect(r image.Rectangle) []byte {
+	return alignedBytes(b.strideRect(r)*r.Dy(), b.align)
 }
 
-func (b *ImageRGBAPool) Put(pix []byte) bool {
+func (b *pixelPool) reset(pix []byte) []byte {
+	return pix[:b.length]
+}
+
+func (b *pixelPool) Put(pix []byte) bool {
+	defer b.pool.release(pix)
+
 	if cap(pix) < b.length {
 		// discard small buffer
+		b.pool.stats.discardTooSmall()
 		return false
 	}
-
-	select {
-	case b.pool <- pix[:b.length]:
-		// free capacity
-		return true
-	default:
-		// full capacity, discard it
+	if isAligned(pix, b.align) != true {
+		// discard, not allocated by this pool
+		b.pool.stats.discardForeign()
 		return false
 	}
+
+	return b.pool.store(pix)
 }
 
-func (b *ImageRGBAPool) Len() int {
-	return len(b.pool)
+func (b *pixelPool) Len() int {
+	return b.pool.Len()
 }
 
-func (b *ImageRGBAPool) Cap() int {
-	return cap(b.pool)
+func (b *pixelPool) Cap() int {
+	return b.pool.Cap()
 }
 
-func NewImageRGBAPool(poolSize int, rect image.Rectangle, funcs ...optionFunc) *ImageRGBAPool {
-	opt := newOption()
-	for _, fn := range funcs {
-		fn(opt)
-	}
+func (b *pixelPool) Stats() Stats {
+	return b.pool.Stats()
+}
 
-	b := &ImageRGBAPool{
-		pool: make(chan []byte, poolSize),
-		// other field initialize to b.init(rect, sample)
-	}
-	b.init(rect)
+func (b *pixelPool) Trim() int {
+	return b.pool.Trim()
+}
 
-	if opt.preload {
-		b.preload(opt.preloadRate)
+func (b *pixelPool) Close() error {
+	return b.pool.Close()
+}
+
+func (b *pixelPool) Drain(ctx context.Context) error {
+	return b.pool.Drain(ctx)
+}
+
+func (b *pixelPool) poolStats() *poolStats {
+	return b.pool.stats
+}
+
+func (b *pixelPool) leakDetector() *leakDetector {
+	return b.pool.leak
+}
+
+func (b *pixelPool) metricsLabels() []metricsLabel {
+	return rectMetricsLabels(b.rect)
+}
+
+type ImageRGBAPool struct {
+	pixelPool
+}
+
+func (b *ImageRGBAPool) createImageRGBARef(pix []byte, rect image.Rectangle) *ImageRGBARef {
+	ref := newImageRGBARef(pix, &image.RGBA{
+		Pix:    pix,
+		Stride: imageRGBAStride(rect, b.align),
+		Rect:   rect,
+	}, b)
+	ref.setFinalizer()
+	return ref
+}
+
+func (b *ImageRGBAPool) GetRef() *ImageRGBARef {
+	pix := b.pool.Get()
+	return b.createImageRGBARef(pix, b.rect)
+}
+
+func (b *ImageRGBAPool) GetRefContext(ctx context.Context) (*ImageRGBARef, error) {
+	pix, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
 	}
+	return b.createImageRGBARef(pix, b.rect), nil
+}
+
+func (b *ImageRGBAPool) getRef(r image.Rectangle) *ImageRGBARef {
+	return b.createImageRGBARef(b.pool.Get(), r)
+}
+
+// newRef returns image of r which is not pooled, Release puts it back to this pool
+func (b *ImageRGBAPool) newRef(r image.Rectangle) *ImageRGBARef {
+	return b.createImageRGBARef(b.createRect(r), r)
+}
 
+func NewImageRGBAPool(poolSize int, rect image.Rectangle, funcs ...optionFunc) *ImageRGBAPool {
+	b := &ImageRGBAPool{
+		// other field initialize to b.setup(poolSize, rect, 4, funcs)
+	}
+	b.setup(poolSize, rect, 4, funcs)
 	return b
 }
 
@@ -103,46 +173,204 @@ type ImageNRGBAPool struct {
 	ImageRGBAPool
 }
 
-func (b *ImageNRGBAPool) createImageNRGBARef(pix []byte, pool *ImageNRGBAPool) *ImageNRGBARef {
+func (b *ImageNRGBAPool) createImageNRGBARef(pix []byte, rect image.Rectangle) *ImageNRGBARef {
 	ref := newImageNRGBARef(pix, &image.NRGBA{
 		Pix:    pix,
-		Stride: b.stride,
-		Rect:   b.rect,
-	}, pool)
+		Stride: imageRGBAStride(rect, b.align),
+		Rect:   rect,
+	}, b)
 	ref.setFinalizer()
 	return ref
 }
 
 func (b *ImageNRGBAPool) GetRef() *ImageNRGBARef {
-	var pix []byte
-	select {
-	case pix = <-b.pool:
-		// reuse exists pool
-	default:
-		// create []byte
-		pix = make([]byte, b.length)
+	pix := b.pool.Get()
+	return b.createImageNRGBARef(pix, b.rect)
+}
+
+func (b *ImageNRGBAPool) GetRefContext(ctx context.Context) (*ImageNRGBARef, error) {
+	pix, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
 	}
-	return b.createImageNRGBARef(pix, b)
+	return b.createImageNRGBARef(pix, b.rect), nil
+}
+
+func (b *ImageNRGBAPool) getRef(r image.Rectangle) *ImageNRGBARef {
+	return b.createImageNRGBARef(b.pool.Get(), r)
+}
+
+func (b *ImageNRGBAPool) newRef(r image.Rectangle) *ImageNRGBARef {
+	return b.createImageNRGBARef(b.createRect(r), r)
 }
 
 func NewImageNRGBAPool(poolSize int, rect image.Rectangle, funcs ...optionFunc) *ImageNRGBAPool {
-	opt := newOption()
-	for _, fn := range funcs {
-		fn(opt)
+	b := new(ImageNRGBAPool)
+	b.setup(poolSize, rect, 4, funcs)
+	return b
+}
+
+// imagePool is pool of image which has bytesPerPixel, newImage builds image on pooled pixels
+type imagePool[I image.Image] struct {
+	pixelPool
+	newImage func(pix []byte, stride int, rect image.Rectangle) I
+}
+
+func (b *imagePool[I]) setup(poolSize int, rect image.Rectangle, bytesPerPixel int, newImage func([]byte, int, image.Rectangle) I, funcs []optionFunc) {
+	b.newImage = newImage
+	b.pixelPool.setup(poolSize, rect, bytesPerPixel, funcs)
+}
+
+func (b *imagePool[I]) createImageRef(pix []byte, rect image.Rectangle) *ImageRef[I] {
+	ref := newImageRef[I](pix, b.newImage(pix, b.strideRect(rect), rect), b)
+	ref.setFinalizer()
+	return ref
+}
+
+func (b *imagePool[I]) GetRef() *ImageRef[I] {
+	pix := b.pool.Get()
+	return b.createImageRef(pix, b.rect)
+}
+
+func (b *imagePool[I]) GetRefContext(ctx context.Context) (*ImageRef[I], error) {
+	pix, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
 	}
+	return b.createImageRef(pix, b.rect), nil
+}
 
-	b := new(ImageNRGBAPool)
-	b.pool = make(chan []byte, poolSize)
-	b.init(rect)
+func (b *imagePool[I]) getRef(r image.Rectangle) *ImageRef[I] {
+	return b.createImageRef(b.pool.Get(), r)
+}
 
-	if opt.preload {
-		b.preload(opt.preloadRate)
+func (b *imagePool[I]) newRef(r image.Rectangle) *ImageRef[I] {
+	return b.createImageRef(b.createRect(r), r)
+}
+
+type ImageGrayPool struct {
+	imagePool[*image.Gray]
+}
+
+func NewImageGrayPool(poolSize int, rect image.Rectangle, funcs ...optionFunc) *ImageGrayPool {
+	b := new(ImageGrayPool)
+	b.setup(poolSize, rect, 1, func(pix []byte, stride int, rect image.Rectangle) *image.Gray {
+		return &image.Gray{Pix: pix, Stride: stride, Rect: rect}
+	}, funcs)
+	return b
+}
+
+type ImageGray16Pool struct {
+	imagePool[*image.Gray16]
+}
+
+func NewImageGray16Pool(poolSize int, rect image.Rectangle, funcs ...optionFunc) *ImageGray16Pool {
+	b := new(ImageGray16Pool)
+	b.setup(poolSize, rect, 2, func(pix []byte, stride int, rect image.Rectangle) *image.Gray16 {
+		return &image.Gray16{Pix: pix, Stride: stride, Rect: rect}
+	}, funcs)
+	return b
+}
+
+type ImageAlphaPool struct {
+	imagePool[*image.Alpha]
+}
+
+func NewImageAlphaPool(poolSize int, rect image.Rectangle, funcs ...optionFunc) *ImageAlphaPool {
+	b := new(ImageAlphaPool)
+	b.setup(poolSize, rect, 1, func(pix []byte, stride int, rect image.Rectangle) *image.Alpha {
+		return &image.Alpha{Pix: pix, Stride: stride, Rect: rect}
+	}, funcs)
+	return b
+}
+
+type ImageAlpha16Pool struct {
+	imagePool[*image.Alpha16]
+}
+
+func NewImageAlpha16Pool(poolSize int, rect image.Rectangle, funcs ...optionFunc) *ImageAlpha16Pool {
+	b := new(ImageAlpha16Pool)
+	b.setup(poolSize, rect, 2, func(pix []byte, stride int, rect image.Rectangle) *image.Alpha16 {
+		return &image.Alpha16{Pix: pix, Stride: stride, Rect: rect}
+	}, funcs)
+	return b
+}
+
+type ImageRGBA64Pool struct {
+	imagePool[*image.RGBA64]
+}
+
+func NewImageRGBA64Pool(poolSize int, rect image.Rectangle, funcs ...optionFunc) *ImageRGBA64Pool {
+	b := new(ImageRGBA64Pool)
+	b.setup(poolSize, rect, 8, func(pix []byte, stride int, rect image.Rectangle) *image.RGBA64 {
+		return &image.RGBA64{Pix: pix, Stride: stride, Rect: rect}
+	}, funcs)
+	return b
+}
+
+type ImageNRGBA64Pool struct {
+	imagePool[*image.NRGBA64]
+}
+
+func NewImageNRGBA64Pool(poolSize int, rect image.Rectangle, funcs ...optionFunc) *ImageNRGBA64Pool {
+	b := new(ImageNRGBA64Pool)
+	b.setup(poolSize, rect, 8, func(pix []byte, stride int, rect image.Rectangle) *image.NRGBA64 {
+		return &image.NRGBA64{Pix: pix, Stride: stride, Rect: rect}
+	}, funcs)
+	return b
+}
+
+type ImageCMYKPool struct {
+	imagePool[*image.CMYK]
+}
+
+func NewImageCMYKPool(poolSize int, rect image.Rectangle, funcs ...optionFunc) *ImageCMYKPool {
+	b := new(ImageCMYKPool)
+	b.setup(poolSize, rect, 4, func(pix []byte, stride int, rect image.Rectangle) *image.CMYK {
+		return &image.CMYK{Pix: pix, Stride: stride, Rect: rect}
+	}, funcs)
+	return b
+}
+
+type ImagePalettedPool struct {
+	imagePool[*image.Paletted]
+	palette color.Palette
+}
+
+// Palette returns pool-wide palette, it is shared by images of GetRef
+func (b *ImagePalettedPool) Palette() color.Palette {
+	return b.palette
+}
+
+// GetRefWithPalette returns image with palette supplied by caller
+func (b *ImagePalettedPool) GetRefWithPalette(palette color.Palette) *ImagePalettedRef {
+	ref := b.GetRef()
+	ref.Img.Palette = palette
+	return ref
+}
+
+func (b *ImagePalettedPool) GetRefWithPaletteContext(ctx context.Context, palette color.Palette) (*ImagePalettedRef, error) {
+	ref, err := b.GetRefContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ref.Img.Palette = palette
+	return ref, nil
+}
+
+// NewImagePalettedPool returns pool of image.Paletted, palette is shared by images of GetRef (it can be nil).
+func NewImagePalettedPool(poolSize int, rect image.Rectangle, palette color.Palette, funcs ...optionFunc) *ImagePalettedPool {
+	b := &ImagePalettedPool{
+		palette: palette,
 	}
+	b.setup(poolSize, rect, 1, func(pix []byte, stride int, rect image.Rectangle) *image.Paletted {
+		return &image.Paletted{Pix: pix, Stride: stride, Rect: rect, Palette: palette}
+	}, funcs)
 	return b
 }
 
 type ImageYCbCrPool struct {
-	pool     chan []byte
+	pool     *Pool[[]byte]
 	rect     image.Rectangle
 	sample   image.YCbCrSubsampleRatio
 	yIdx     int
@@ -151,26 +379,40 @@ type ImageYCbCrPool struct {
 	strideY  int
 	strideUV int
 	length   int
+	align    int // row and plane alignment, 0 if not aligned
 }
 
 func (b *ImageYCbCrPool) init(rect image.Rectangle, sample image.YCbCrSubsampleRatio) {
-	w, h := rect.Dx(), rect.Dy()
-	cw, ch := yuvSize(rect, sample)
-
-	i0 := (w * h) + (0 * cw * ch)
-	i1 := (w * h) + (1 * cw * ch)
-	i2 := (w * h) + (2 * cw * ch)
+	strideY, strideUV, i0, i1, i2 := yuvPlanes(rect, sample, b.align)
 
 	b.rect = rect
 	b.sample = sample
 	b.yIdx = i0
 	b.uIdx = i1
 	b.vIdx = i2
-	b.strideY = w
-	b.strideUV = cw
+	b.strideY = strideY
+	b.strideUV = strideUV
 	b.length = i2
 }
 
+// setup initializes pool, length is initialized by init
+func (b *ImageYCbCrPool) setup(poolSize int, rect image.Rectangle, sample image.YCbCrSubsampleRatio, init func(image.Rectangle, image.YCbCrSubsampleRatio), funcs []optionFunc) {
+	opt := newOption()
+	for _, fn := range funcs {
+		fn(opt)
+	}
+
+	b.align = opt.strideAlignment
+	init(rect, sample)
+	b.pool = newPool[[]byte](poolSize, b.create, opt)
+	b.pool.budget = newBudgetAccount(opt, b.length)
+	b.pool.resetFunc = b.reset
+
+	if opt.preload {
+		b.pool.preload(opt.preloadRate)
+	}
+}
+
 func (b *ImageYCbCrPool) YStride(stride int) {
 	b.strideY = stride
 }
@@ -179,8 +421,9 @@ func (b *ImageYCbCrPool) UVStride(stride int) {
 	b.strideUV = stride
 }
 
-func (b *ImageYCbCrPool) createImageYCbCrRef(pix []byte, pool *ImageYCbCrPool) *ImageYCbCrRef {
-	ref := newImageYCbCrRef(pix, &image.YCbCr{
+// imageYCbCr returns Y, Cb and Cr planes of pool rect
+func (b *ImageYCbCrPool) imageYCbCr(pix []byte) image.YCbCr {
+	return image.YCbCr{
 		Y:              pix[0:b.yIdx:b.yIdx],
 		Cb:             pix[b.yIdx:b.uIdx:b.uIdx],
 		Cr:             pix[b.uIdx:b.vIdx:b.vIdx],
@@ -188,88 +431,252 @@ func (b *ImageYCbCrPool) createImageYCbCrRef(pix []byte, pool *ImageYCbCrPool) *
 		CStride:        b.strideUV,
 		Rect:           b.rect,
 		SubsampleRatio: b.sample,
-	}, pool)
+	}
+}
+
+// imageYCbCrRect returns Y, Cb and Cr planes of r
+func (b *ImageYCbCrPool) imageYCbCrRect(pix []byte, r image.Rectangle) image.YCbCr {
+	strideY, strideUV, i0, i1, i2 := yuvPlanes(r, b.sample, b.align)
+	return image.YCbCr{
+		Y:              pix[0:i0:i0],
+		Cb:             pix[i0:i1:i1],
+		Cr:             pix[i1:i2:i2],
+		YStride:        strideY,
+		CStride:        strideUV,
+		Rect:           r,
+		SubsampleRatio: b.sample,
+	}
+}
+
+func (b *ImageYCbCrPool) createImageYCbCrRef(pix []byte, img image.YCbCr) *ImageYCbCrRef {
+	ref := newImageYCbCrRef(pix, &img, b)
 	ref.setFinalizer()
 	return ref
 }
 
 func (b *ImageYCbCrPool) GetRef() *ImageYCbCrRef {
-	var pix []byte
-	select {
-	case pix = <-b.pool:
-		// reuse exists pool
-	default:
-		// create []byte
-		pix = make([]byte, b.length)
-	}
-	return b.createImageYCbCrRef(pix, b)
+	pix := b.pool.Get()
+	return b.createImageYCbCrRef(pix, b.imageYCbCr(pix))
 }
 
-func (b *ImageYCbCrPool) preload(rate float64) {
-	if 0 < cap(b.pool) {
-		preloadSize := int(float64(cap(b.pool)) * rate)
-		for i := 0; i < preloadSize; i += 1 {
-			b.Put(make([]byte, b.length))
-		}
+func (b *ImageYCbCrPool) GetRefContext(ctx context.Context) (*ImageYCbCrRef, error) {
+	pix, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
 	}
+	return b.createImageYCbCrRef(pix, b.imageYCbCr(pix)), nil
+}
+
+func (b *ImageYCbCrPool) fits(r image.Rectangle) bool {
+	// chroma planes of odd origin are larger than the pool rect of same size
+	return r.Empty() != true && rectIn(b.rect, r) && yuvLength(r, b.sample, b.align) <= b.length
+}
+
+func (b *ImageYCbCrPool) getRef(r image.Rectangle) *ImageYCbCrRef {
+	pix := b.pool.Get()
+	return b.createImageYCbCrRef(pix, b.imageYCbCrRect(pix, r))
+}
+
+func (b *ImageYCbCrPool) newRef(r image.Rectangle) *ImageYCbCrRef {
+	pix := alignedBytes(yuvLength(r, b.sample, b.align), b.align)
+	return b.createImageYCbCrRef(pix, b.imageYCbCrRect(pix, r))
+}
+
+func (b *ImageYCbCrPool) create() []byte {
+	// create []byte
+	return alignedBytes(b.length, b.align)
+}
+
+func (b *ImageYCbCrPool) reset(pix []byte) []byte {
+	return pix[:b.length]
 }
 
 func (b *ImageYCbCrPool) Put(pix []byte) bool {
+	defer b.pool.release(pix)
+
 	if cap(pix) < b.length {
 		// discard small buffer
+		b.pool.stats.discardTooSmall()
 		return false
 	}
-
-	select {
-	case b.pool <- pix[:b.length]:
-		// free capacity
-		return true
-	default:
-		// full capacity, discard it
+	if isAligned(pix, b.align) != true {
+		// discard, not allocated by this pool
+		b.pool.stats.discardForeign()
 		return false
 	}
+
+	return b.pool.store(pix)
 }
 
 func (b *ImageYCbCrPool) Len() int {
-	return len(b.pool)
+	return b.pool.Len()
 }
 
 func (b *ImageYCbCrPool) Cap() int {
-	return cap(b.pool)
+	return b.pool.Cap()
 }
 
-func NewImageYCbCrPool(poolSize int, rect image.Rectangle, sample image.YCbCrSubsampleRatio, funcs ...optionFunc) *ImageYCbCrPool {
-	opt := newOption()
-	for _, fn := range funcs {
-		fn(opt)
-	}
+func (b *ImageYCbCrPool) Stats() Stats {
+	return b.pool.Stats()
+}
 
-	if sample != image.YCbCrSubsampleRatio420 {
-		panic(notyetSupportedSampleRate)
-	}
+func (b *ImageYCbCrPool) Trim() int {
+	return b.pool.Trim()
+}
+
+func (b *ImageYCbCrPool) Close() error {
+	return b.pool.Close()
+}
+
+func (b *ImageYCbCrPool) Drain(ctx context.Context) error {
+	return b.pool.Drain(ctx)
+}
+
+func (b *ImageYCbCrPool) poolStats() *poolStats {
+	return b.pool.stats
+}
+
+func (b *ImageYCbCrPool) leakDetector() *leakDetector {
+	return b.pool.leak
+}
+
+func (b *ImageYCbCrPool) metricsLabels() []metricsLabel {
+	return rectMetricsLabels(b.rect)
+}
+
+func NewImageYCbCrPool(poolSize int, rect image.Rectangle, sample image.YCbCrSubsampleRatio, funcs ...optionFunc) *ImageYCbCrPool {
 	b := &ImageYCbCrPool{
-		pool: make(chan []byte, poolSize),
 		// other field initialize to b.init(rect, sample)
 	}
-	b.init(rect, sample)
+	b.setup(poolSize, rect, sample, b.init, funcs)
+	return b
+}
 
-	if opt.preload {
-		b.preload(opt.preloadRate)
+type ImageNYCbCrAPool struct {
+	ImageYCbCrPool
+	aIdx    int
+	strideA int
+}
+
+func (b *ImageNYCbCrAPool) init(rect image.Rectangle, sample image.YCbCrSubsampleRatio) {
+	b.ImageYCbCrPool.init(rect, sample)
+
+	// alpha plane follows Cr
+	b.strideA = imageStride(rect, 1, b.align)
+	b.aIdx = b.vIdx + (b.strideA * rect.Dy())
+	b.length = b.aIdx
+}
+
+func (b *ImageNYCbCrAPool) AStride(stride int) {
+	b.strideA = stride
+}
+
+func (b *ImageNYCbCrAPool) createImageNYCbCrARef(pix []byte, img image.YCbCr, a []byte, strideA int) *ImageNYCbCrARef {
+	ref := newImageRef[*image.NYCbCrA](pix, &image.NYCbCrA{
+		YCbCr:   img,
+		A:       a,
+		AStride: strideA,
+	}, b)
+	ref.setFinalizer()
+	return ref
+}
+
+// createImageNYCbCrARect returns image of r, alpha plane follows Cr
+func (b *ImageNYCbCrAPool) createImageNYCbCrARect(pix []byte, r image.Rectangle) *ImageNYCbCrARef {
+	i2 := yuvLength(r, b.sample, b.align)
+	strideA := imageStride(r, 1, b.align)
+	i3 := i2 + (strideA * r.Dy())
+	return b.createImageNYCbCrARef(pix, b.imageYCbCrRect(pix, r), pix[i2:i3:i3], strideA)
+}
+
+func (b *ImageNYCbCrAPool) GetRef() *ImageNYCbCrARef {
+	pix := b.pool.Get()
+	return b.createImageNYCbCrARef(pix, b.imageYCbCr(pix), pix[b.vIdx:b.aIdx:b.aIdx], b.strideA)
+}
+
+func (b *ImageNYCbCrAPool) GetRefContext(ctx context.Context) (*ImageNYCbCrARef, error) {
+	pix, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return b.createImageNYCbCrARef(pix, b.imageYCbCr(pix), pix[b.vIdx:b.aIdx:b.aIdx], b.strideA), nil
+}
+
+func (b *ImageNYCbCrAPool) fits(r image.Rectangle) bool {
+	return r.Empty() != true && rectIn(b.rect, r) && nycbcraLength(r, b.sample, b.align) <= b.length
+}
+
+func (b *ImageNYCbCrAPool) getRef(r image.Rectangle) *ImageNYCbCrARef {
+	return b.createImageNYCbCrARect(b.pool.Get(), r)
+}
+
+func (b *ImageNYCbCrAPool) newRef(r image.Rectangle) *ImageNYCbCrARef {
+	pix := alignedBytes(nycbcraLength(r, b.sample, b.align), b.align)
+	return b.createImageNYCbCrARect(pix, r)
+}
+
+func NewImageNYCbCrAPool(poolSize int, rect image.Rectangle, sample image.YCbCrSubsampleRatio, funcs ...optionFunc) *ImageNYCbCrAPool {
+	b := &ImageNYCbCrAPool{
+		// other field initialize to b.init(rect, sample)
 	}
+	b.setup(poolSize, rect, sample, b.init, funcs)
 	return b
 }
 
-func imageRGBAStride(rect image.Rectangle) int {
-	return rect.Dx() * 4
+func imageRGBAStride(rect image.Rectangle, align int) int {
+	return imageStride(rect, 4, align)
 }
 
+// imageStride returns bytes of row, padded to multiple of align
+func imageStride(rect image.Rectangle, bytesPerPixel int, align int) int {
+	return alignUp(rect.Dx()*bytesPerPixel, align)
+}
+
+// yuvSize returns size of chroma plane, same as image.NewYCbCr for rect with non-zero origin
 func yuvSize(rect image.Rectangle, sample image.YCbCrSubsampleRatio) (int, int) {
 	w, h := rect.Dx(), rect.Dy()
-	if sample == image.YCbCrSubsampleRatio420 {
+	switch sample {
+	case image.YCbCrSubsampleRatio422:
+		cw := ((rect.Max.X + 1) / 2) - (rect.Min.X / 2)
+		return cw, h
+	case image.YCbCrSubsampleRatio420:
 		cw := ((rect.Max.X + 1) / 2) - (rect.Min.X / 2)
 		ch := ((rect.Max.Y + 1) / 2) - (rect.Min.Y / 2)
 		return cw, ch
+	case image.YCbCrSubsampleRatio440:
+		ch := ((rect.Max.Y + 1) / 2) - (rect.Min.Y / 2)
+		return w, ch
+	case image.YCbCrSubsampleRatio411:
+		cw := ((rect.Max.X + 3) / 4) - (rect.Min.X / 4)
+		return cw, h
+	case image.YCbCrSubsampleRatio410:
+		cw := ((rect.Max.X + 3) / 4) - (rect.Min.X / 4)
+		ch := ((rect.Max.Y + 1) / 2) - (rect.Min.Y / 2)
+		return cw, ch
 	}
 	// 4:4:4
 	return w, h
 }
+
+// yuvPlanes returns strides and end index of Y, Cb and Cr planes, each plane starts at multiple of align
+func yuvPlanes(rect image.Rectangle, sample image.YCbCrSubsampleRatio, align int) (int, int, int, int, int) {
+	cw, ch := yuvSize(rect, sample)
+	strideY := imageStride(rect, 1, align)
+	strideUV := alignUp(cw, align)
+
+	i0 := strideY * rect.Dy()
+	i1 := i0 + (strideUV * ch)
+	i2 := i1 + (strideUV * ch)
+	return strideY, strideUV, i0, i1, i2
+}
+
+// yuvLength returns length of Y, Cb and Cr planes
+func yuvLength(rect image.Rectangle, sample image.YCbCrSubsampleRatio, align int) int {
+	_, _, _, _, i2 := yuvPlanes(rect, sample, align)
+	return i2
+}
+
+// nycbcraLength returns length of Y, Cb, Cr and alpha planes
+func nycbcraLength(rect image.Rectangle, sample image.YCbCrSubsampleRatio, align int) int {
+	return yuvLength(rect, sample, align) + (imageStride(rect, 1, align) * rect.Dy())
+}
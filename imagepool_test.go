@@ -1,10 +1,15 @@
 package bp
 
 import (
+	"context"
+	"fmt"
 	"image"
+	"image/color"
+	"image/color/palette"
 	"runtime"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/octu0/chanque"
 )
@@ -506,38 +511,50 @@ func TestImageYCbCrPoolCapLen(t *testing.T) {
 	})
 }
 
-func TestYCbCrPoolPanic(t *testing.T) {
-	t.Run("no panic i420", func(tt *testing.T) {
-		defer func() {
-			r := recover()
-			if r != nil {
-				tt.Errorf("no panic i420")
-			}
-		}()
-
-		rect := image.Rect(0, 0, 16, 9)
-		i420 := image.YCbCrSubsampleRatio420
-		_ = NewImageYCbCrPool(10, rect, i420)
-	})
-	t.Run("panic sample != i420", func(tt *testing.T) {
-		defer func() {
-			r := recover()
-			if r == nil {
-				tt.Errorf("must panic")
-			}
-			s, ok := r.(string)
-			if ok != true {
-				tt.Errorf("panic string")
-			}
-			if s != notyetSupportedSampleRate {
-				tt.Errorf("other panic")
-			}
-		}()
+func TestYCbCrPoolSubsampleRatio(t *testing.T) {
+	samples := []image.YCbCrSubsampleRatio{
+		image.YCbCrSubsampleRatio444,
+		image.YCbCrSubsampleRatio422,
+		image.YCbCrSubsampleRatio420,
+		image.YCbCrSubsampleRatio440,
+		image.YCbCrSubsampleRatio411,
+		image.YCbCrSubsampleRatio410,
+	}
+	rects := []image.Rectangle{
+		image.Rect(0, 0, 16, 9),
+		image.Rect(1, 3, 17, 12),
+		image.Rect(3, 1, 10, 6),
+	}
+	for _, sample := range samples {
+		for _, rect := range rects {
+			t.Run(fmt.Sprintf("%s %s", sample, rect), func(tt *testing.T) {
+				expect := image.NewYCbCr(rect, sample)
 
-		rect := image.Rect(0, 0, 16, 9)
-		i410 := image.YCbCrSubsampleRatio410
-		_ = NewImageYCbCrPool(10, rect, i410)
-	})
+				p := NewImageYCbCrPool(10, rect, sample)
+				ref := p.GetRef()
+				defer ref.Release()
+
+				img := ref.Image()
+				if len(img.Y) != len(expect.Y) || len(img.Cb) != len(expect.Cb) || len(img.Cr) != len(expect.Cr) {
+					tt.Errorf("plane len Y=%d Cb=%d Cr=%d", len(img.Y), len(img.Cb), len(img.Cr))
+				}
+				if img.YStride != expect.YStride || img.CStride != expect.CStride {
+					tt.Errorf("stride Y=%d C=%d", img.YStride, img.CStride)
+				}
+				if img.SubsampleRatio != sample {
+					tt.Errorf("sample = %s", img.SubsampleRatio)
+				}
+				// every pixel is addressable
+				for y := rect.Min.Y; y < rect.Max.Y; y += 1 {
+					for x := rect.Min.X; x < rect.Max.X; x += 1 {
+						img.Y[img.YOffset(x, y)] = 0xff
+						img.Cb[img.COffset(x, y)] = 0xff
+						img.Cr[img.COffset(x, y)] = 0xff
+					}
+				}
+			})
+		}
+	}
 }
 
 func TestImageRGBAPoolPreload(t *testing.T) {
@@ -566,3 +583,330 @@ func TestImageYCbCrPoolPreload(t *testing.T) {
 		t.Errorf("preloaded buffer = %d", p.Len())
 	}
 }
+
+func TestImagePoolGetRefContext(t *testing.T) {
+	rect := image.Rect(0, 0, 16, 16)
+	t.Run("rgba", func(tt *testing.T) {
+		p := NewImageRGBAPool(10, rect, MaxOutstanding(1))
+		r1, err := p.GetRefContext(context.Background())
+		if err != nil {
+			tt.Fatalf("no error: %+v", err)
+		}
+		if r1.Img.Rect.Eq(rect) != true {
+			tt.Errorf("rect = %v", r1.Img.Rect)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := p.GetRefContext(ctx); err != context.DeadlineExceeded {
+			tt.Errorf("deadline exceeded: %+v", err)
+		}
+
+		r1.Release()
+		r2, err := p.GetRefContext(ctx)
+		if err != nil {
+			tt.Errorf("released: %+v", err)
+		}
+		r2.Release()
+	})
+	t.Run("nrgba", func(tt *testing.T) {
+		p := NewImageNRGBAPool(10, rect, MaxOutstanding(1))
+		r1, _ := p.GetRefContext(context.Background())
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := p.GetRefContext(ctx); err != context.Canceled {
+			tt.Errorf("canceled: %+v", err)
+		}
+		r1.Release()
+	})
+	t.Run("ycbcr", func(tt *testing.T) {
+		p := NewImageYCbCrPool(10, rect, image.YCbCrSubsampleRatio420, MaxOutstanding(1))
+		r1, _ := p.GetRefContext(context.Background())
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := p.GetRefContext(ctx); err != context.Canceled {
+			tt.Errorf("canceled: %+v", err)
+		}
+		r1.Release()
+	})
+}
+
+type testImagePool interface {
+	Put([]byte) bool
+	Len() int
+	Stats() Stats
+}
+
+func TestImagePoolStdTypes(t *testing.T) {
+	tests := []struct {
+		name   string
+		get    func(int, image.Rectangle, ...optionFunc) (testImagePool, Ref, []byte, int)
+		expect func(image.Rectangle) ([]byte, int)
+	}{
+		{
+			name: "Gray",
+			get: func(p int, r image.Rectangle, funcs ...optionFunc) (testImagePool, Ref, []byte, int) {
+				pool := NewImageGrayPool(p, r, funcs...)
+				ref := pool.GetRef()
+				return pool, ref, ref.Img.Pix, ref.Img.Stride
+			},
+			expect: func(r image.Rectangle) ([]byte, int) {
+				img := image.NewGray(r)
+				return img.Pix, img.Stride
+			},
+		},
+		{
+			name: "Gray16",
+			get: func(p int, r image.Rectangle, funcs ...optionFunc) (testImagePool, Ref, []byte, int) {
+				pool := NewImageGray16Pool(p, r, funcs...)
+				ref := pool.GetRef()
+				return pool, ref, ref.Img.Pix, ref.Img.Stride
+			},
+			expect: func(r image.Rectangle) ([]byte, int) {
+				img := image.NewGray16(r)
+				return img.Pix, img.Stride
+			},
+		},
+		{
+			name: "Alpha",
+			get: func(p int, r image.Rectangle, funcs ...optionFunc) (testImagePool, Ref, []byte, int) {
+				pool := NewImageAlphaPool(p, r, funcs...)
+				ref := pool.GetRef()
+				return pool, ref, ref.Img.Pix, ref.Img.Stride
+			},
+			expect: func(r image.Rectangle) ([]byte, int) {
+				img := image.NewAlpha(r)
+				return img.Pix, img.Stride
+			},
+		},
+		{
+			name: "Alpha16",
+			get: func(p int, r image.Rectangle, funcs ...optionFunc) (testImagePool, Ref, []byte, int) {
+				pool := NewImageAlpha16Pool(p, r, funcs...)
+				ref := pool.GetRef()
+				return pool, ref, ref.Img.Pix, ref.Img.Stride
+			},
+			expect: func(r image.Rectangle) ([]byte, int) {
+				img := image.NewAlpha16(r)
+				return img.Pix, img.Stride
+			},
+		},
+		{
+			name: "RGBA64",
+			get: func(p int, r image.Rectangle, funcs ...optionFunc) (testImagePool, Ref, []byte, int) {
+				pool := NewImageRGBA64Pool(p, r, funcs...)
+				ref := pool.GetRef()
+				return pool, ref, ref.Img.Pix, ref.Img.Stride
+			},
+			expect: func(r image.Rectangle) ([]byte, int) {
+				img := image.NewRGBA64(r)
+				return img.Pix, img.Stride
+			},
+		},
+		{
+			name: "NRGBA64",
+			get: func(p int, r image.Rectangle, funcs ...optionFunc) (testImagePool, Ref, []byte, int) {
+				pool := NewImageNRGBA64Pool(p, r, funcs...)
+				ref := pool.GetRef()
+				return pool, ref, ref.Img.Pix, ref.Img.Stride
+			},
+			expect: func(r image.Rectangle) ([]byte, int) {
+				img := image.NewNRGBA64(r)
+				return img.Pix, img.Stride
+			},
+		},
+		{
+			name: "CMYK",
+			get: func(p int, r image.Rectangle, funcs ...optionFunc) (testImagePool, Ref, []byte, int) {
+				pool := NewImageCMYKPool(p, r, funcs...)
+				ref := pool.GetRef()
+				return pool, ref, ref.Img.Pix, ref.Img.Stride
+			},
+			expect: func(r image.Rectangle) ([]byte, int) {
+				img := image.NewCMYK(r)
+				return img.Pix, img.Stride
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(tt *testing.T) {
+			for _, r := range []image.Rectangle{image.Rect(0, 0, 16, 9), image.Rect(3, 5, 20, 11)} {
+				pool, ref, pix, stride := tc.get(10, r)
+				expectPix, expectStride := tc.expect(r)
+				if len(pix) != len(expectPix) || stride != expectStride {
+					tt.Errorf("%s len=%d stride=%d", r, len(pix), stride)
+				}
+				ref.Release()
+				if pool.Len() != 1 {
+					tt.Errorf("%s release to pool", r)
+				}
+				if pool.Put(make([]byte, len(expectPix)-1)) {
+					tt.Errorf("%s discard small buffer", r)
+				}
+				if s := pool.Stats(); s.PutDiscardTooSmall != 1 {
+					tt.Errorf("%s discard too small = %d", r, s.PutDiscardTooSmall)
+				}
+			}
+		})
+		t.Run(tc.name+" preload", func(tt *testing.T) {
+			pool, ref, _, _ := tc.get(12, image.Rect(0, 0, 100, 100), Preload(true))
+			ref.Release()
+			if l := int(float64(12) * defaultPreloadRate); pool.Len() != l {
+				tt.Errorf("preloaded buffer = %d", pool.Len())
+			}
+		})
+	}
+}
+
+func TestImageNYCbCrAPool(t *testing.T) {
+	samples := []image.YCbCrSubsampleRatio{
+		image.YCbCrSubsampleRatio444,
+		image.YCbCrSubsampleRatio422,
+		image.YCbCrSubsampleRatio420,
+		image.YCbCrSubsampleRatio440,
+		image.YCbCrSubsampleRatio411,
+		image.YCbCrSubsampleRatio410,
+	}
+	rects := []image.Rectangle{
+		image.Rect(0, 0, 16, 9),
+		image.Rect(1, 3, 17, 12),
+	}
+	for _, sample := range samples {
+		for _, rect := range rects {
+			t.Run(fmt.Sprintf("%s %s", sample, rect), func(tt *testing.T) {
+				expect := image.NewNYCbCrA(rect, sample)
+
+				p := NewImageNYCbCrAPool(10, rect, sample)
+				ref := p.GetRef()
+
+				img := ref.Image()
+				if len(img.Y) != len(expect.Y) || len(img.Cb) != len(expect.Cb) || len(img.Cr) != len(expect.Cr) || len(img.A) != len(expect.A) {
+					tt.Errorf("plane len Y=%d Cb=%d Cr=%d A=%d", len(img.Y), len(img.Cb), len(img.Cr), len(img.A))
+				}
+				if img.YStride != expect.YStride || img.CStride != expect.CStride || img.AStride != expect.AStride {
+					tt.Errorf("stride Y=%d C=%d A=%d", img.YStride, img.CStride, img.AStride)
+				}
+				// planes do not overlap
+				for y := rect.Min.Y; y < rect.Max.Y; y += 1 {
+					for x := rect.Min.X; x < rect.Max.X; x += 1 {
+						img.A[img.AOffset(x, y)] = 0xff
+					}
+				}
+				for _, c := range img.Cr {
+					if c != 0 {
+						tt.Errorf("alpha overlaps Cr")
+						break
+					}
+				}
+
+				ref.Release()
+				if p.Len() != 1 {
+					tt.Errorf("release to pool")
+				}
+				if p.Put(make([]byte, len(expect.Y)+len(expect.Cb)+len(expect.Cr))) {
+					tt.Errorf("discard buffer without alpha plane")
+				}
+			})
+		}
+	}
+}
+
+func TestImagePalettedPool(t *testing.T) {
+	t.Run("palette", func(tt *testing.T) {
+		rect := image.Rect(0, 0, 16, 9)
+		p := NewImagePalettedPool(10, rect, palette.Plan9)
+
+		ref := p.GetRef()
+		img := ref.Image()
+		if len(img.Palette) != len(palette.Plan9) || &img.Palette[0] != &p.Palette()[0] {
+			tt.Errorf("pool-wide palette is shared")
+		}
+		expect := image.NewPaletted(rect, palette.Plan9)
+		if len(img.Pix) != len(expect.Pix) || img.Stride != expect.Stride {
+			tt.Errorf("len=%d stride=%d", len(img.Pix), img.Stride)
+		}
+		img.SetColorIndex(15, 8, 3)
+		ref.Release()
+
+		bw := color.Palette{color.Black, color.White}
+		ref2 := p.GetRefWithPalette(bw)
+		if len(ref2.Image().Palette) != 2 {
+			tt.Errorf("caller palette")
+		}
+		if ref2.Image().ColorIndexAt(15, 8) != 3 {
+			tt.Errorf("reuse released frame")
+		}
+		ref2.Release()
+		if p.Len() != 1 {
+			tt.Errorf("release to pool")
+		}
+	})
+	t.Run("context", func(tt *testing.T) {
+		p := NewImagePalettedPool(10, image.Rect(0, 0, 16, 9), nil, MaxOutstanding(1))
+		r1, err := p.GetRefContext(context.Background())
+		if err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := p.GetRefWithPaletteContext(ctx, palette.WebSafe); err != context.DeadlineExceeded {
+			tt.Errorf("wait outstanding: %+v", err)
+		}
+		r1.Release()
+	})
+}
+
+func TestImagePoolStrideAlignment(t *testing.T) {
+	rect := image.Rect(1, 1, 31, 11)
+	t.Run("rgba", func(tt *testing.T) {
+		p := NewImageRGBAPool(10, rect, StrideAlignment(64))
+		ref := p.GetRef()
+		img := ref.Image()
+		if img.Stride != 128 || isAligned(img.Pix, 64) != true {
+			tt.Errorf("stride = %d", img.Stride)
+		}
+		img.Set(rect.Max.X-1, rect.Max.Y-1, color.White)
+		ref.Release()
+
+		if p.Put(make([]byte, len(img.Pix)+1)[1:]) {
+			tt.Errorf("unaligned pix is discarded")
+		}
+	})
+	t.Run("gray", func(tt *testing.T) {
+		p := NewImageGrayPool(10, rect, StrideAlignment(32))
+		img := p.GetRef().Image()
+		if img.Stride != 32 || isAligned(img.Pix, 32) != true {
+			tt.Errorf("stride = %d", img.Stride)
+		}
+	})
+	t.Run("paletted", func(tt *testing.T) {
+		p := NewImagePalettedPool(10, rect, palette.Plan9, StrideAlignment(64))
+		img := p.GetRef().Image()
+		if img.Stride != 64 || isAligned(img.Pix, 64) != true {
+			tt.Errorf("stride = %d", img.Stride)
+		}
+	})
+	t.Run("ycbcr", func(tt *testing.T) {
+		for _, sample := range []image.YCbCrSubsampleRatio{image.YCbCrSubsampleRatio420, image.YCbCrSubsampleRatio411} {
+			p := NewImageYCbCrPool(10, rect, sample, StrideAlignment(32))
+			img := p.GetRef().Image()
+			if img.YStride%32 != 0 || img.CStride%32 != 0 {
+				tt.Errorf("%s stride Y=%d C=%d", sample, img.YStride, img.CStride)
+			}
+			for _, plane := range [][]byte{img.Y, img.Cb, img.Cr} {
+				if isAligned(plane, 32) != true {
+					tt.Errorf("%s plane is not aligned", sample)
+				}
+			}
+			img.Cr[img.COffset(rect.Max.X-1, rect.Max.Y-1)] = 0xff
+		}
+	})
+	t.Run("nycbcra", func(tt *testing.T) {
+		p := NewImageNYCbCrAPool(10, rect, image.YCbCrSubsampleRatio420, StrideAlignment(64))
+		img := p.GetRef().Image()
+		if img.AStride != 64 || isAligned(img.A, 64) != true {
+			tt.Errorf("alpha stride = %d", img.AStride)
+		}
+		img.A[img.AOffset(rect.Max.X-1, rect.Max.Y-1)] = 0xff
+	})
+}
@@ -0,0 +1,111 @@
+package bp
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	leakStackDepth int = 32
+	// skip runtime.Callers, captureRefStack, acquireRef and newXXXRef
+	leakStackSkip int = 4
+)
+
+// LeakInfo reports a Ref that was not released and collected by GC
+type LeakInfo struct {
+	Ref   string // type name of Ref
+	Stack string // stack trace of GetRef
+}
+
+type LeakHandlerFunc func(LeakInfo)
+
+type leakDetector struct {
+	handler LeakHandlerFunc
+}
+
+// report calls handler with leaked Ref, or logs it if handler is not set
+func (l *leakDetector) report(ref interface{}, stack []uintptr) {
+	info := LeakInfo{
+		Ref:   fmt.Sprintf("%T", ref),
+		Stack: formatRefStack(stack),
+	}
+	if l.handler == nil {
+		log.Printf("warn: bp: %s was not released, acquired at:\n%s", info.Ref, info.Stack)
+		return
+	}
+	l.handler(info)
+}
+
+func newLeakDetector(opt *option) *leakDetector {
+	if opt.leakDetect != true {
+		return nil
+	}
+	return &leakDetector{
+		handler: opt.leakHandler,
+	}
+}
+
+type refTracker interface {
+	poolStats() *poolStats
+	leakDetector() *leakDetector
+}
+
+// acquireRef records acquired Ref and returns stack of GetRef if leak detection is enabled
+func acquireRef(pool interface{}) []uintptr {
+	t, ok := pool.(refTracker)
+	if ok != true {
+		return nil
+	}
+
+	t.poolStats().acquired()
+	if t.leakDetector() != nil {
+		return captureRefStack()
+	}
+	return nil
+}
+
+// releaseRef records released Ref and reports leak if it was released by finalizer
+func releaseRef(pool interface{}, finalized bool, ref interface{}, stack []uintptr) {
+	t, ok := pool.(refTracker)
+	if ok != true {
+		return
+	}
+
+	t.poolStats().released(finalized)
+	if finalized {
+		if l := t.leakDetector(); l != nil {
+			l.report(ref, stack)
+		}
+	}
+}
+
+func captureRefStack() []uintptr {
+	pc := make([]uintptr, leakStackDepth)
+	n := runtime.Callers(leakStackSkip, pc)
+	return pc[:n]
+}
+
+func formatRefStack(stack []uintptr) string {
+	if len(stack) < 1 {
+		return ""
+	}
+
+	sb := new(strings.Builder)
+	frames := runtime.CallersFrames(stack)
+	for {
+		f, more := frames.Next()
+		sb.WriteString(f.Function)
+		sb.WriteString("\n\t")
+		sb.WriteString(f.File)
+		sb.WriteString(":")
+		sb.WriteString(strconv.Itoa(f.Line))
+		sb.WriteString("\n")
+		if more != true {
+			break
+		}
+	}
+	return sb.String()
+}
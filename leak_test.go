@@ -0,0 +1,113 @@
+package bp
+
+import (
+	"bytes"
+	"image"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func waitLeak(ch chan LeakInfo) (LeakInfo, bool) {
+	for i := 0; i < 10; i += 1 {
+		runtime.GC()
+		select {
+		case info := <-ch:
+			return info, true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return LeakInfo{}, false
+}
+
+func TestLeakDetect(t *testing.T) {
+	t.Run("byte", func(tt *testing.T) {
+		ch := make(chan LeakInfo, 1)
+		p := NewBytePool(10, 8, LeakHandler(func(info LeakInfo) {
+			ch <- info
+		}))
+		func() {
+			p.GetRef()
+		}()
+
+		info, ok := waitLeak(ch)
+		if ok != true {
+			tt.Fatalf("leak not detected")
+		}
+		if info.Ref != "*bp.ByteRef" {
+			tt.Errorf("ref type = %s", info.Ref)
+		}
+		if strings.Contains(info.Stack, "bp.(*BytePool).GetRef") != true {
+			tt.Errorf("stack contains GetRef: %s", info.Stack)
+		}
+		if strings.Contains(info.Stack, "leak_test.go") != true {
+			tt.Errorf("stack contains caller: %s", info.Stack)
+		}
+		if s := p.Stats(); s.RefFinalized != 1 {
+			tt.Errorf("finalized = %d", s.RefFinalized)
+		}
+	})
+	t.Run("multi", func(tt *testing.T) {
+		ch := make(chan LeakInfo, 1)
+		mp := NewMultiImageRGBAPool(
+			MultiImagePoolSize(10, image.Rect(0, 0, 10, 10)),
+			MultiImagePoolOption(LeakHandler(func(info LeakInfo) {
+				ch <- info
+			})),
+		)
+		func() {
+			mp.GetRef(image.Rect(0, 0, 8, 8))
+		}()
+
+		info, ok := waitLeak(ch)
+		if ok != true {
+			tt.Fatalf("leak not detected")
+		}
+		if info.Ref != "*bp.ImageRGBARef" {
+			tt.Errorf("ref type = %s", info.Ref)
+		}
+	})
+	t.Run("released", func(tt *testing.T) {
+		ch := make(chan LeakInfo, 1)
+		p := NewBytePool(10, 8, LeakHandler(func(info LeakInfo) {
+			ch <- info
+		}))
+		func() {
+			ref := p.GetRef()
+			ref.Release()
+		}()
+
+		if _, ok := waitLeak(ch); ok {
+			tt.Errorf("released ref is not leak")
+		}
+	})
+	t.Run("log", func(tt *testing.T) {
+		out := new(bytes.Buffer)
+		log.SetOutput(out)
+		defer log.SetOutput(os.Stderr)
+
+		l1 := newLeakDetector(&option{leakDetect: true})
+		l1.report(new(ByteRef), nil)
+		if strings.Contains(out.String(), "*bp.ByteRef was not released") != true {
+			tt.Errorf("log without handler: %s", out.String())
+		}
+
+		out.Reset()
+		l2 := newLeakDetector(&option{leakDetect: true, leakHandler: func(LeakInfo) {}})
+		l2.report(new(ByteRef), nil)
+		if out.Len() != 0 {
+			tt.Errorf("handler replaces log: %s", out.String())
+		}
+	})
+	t.Run("disabled", func(tt *testing.T) {
+		p := NewBytePool(10, 8)
+		ref := p.GetRef()
+		if ref.stack != nil {
+			tt.Errorf("no stack captured")
+		}
+		ref.Release()
+	})
+}
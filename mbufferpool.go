@@ -2,6 +2,7 @@ package bp
 
 import (
 	"bytes"
+	"context"
 	"sort"
 )
 
@@ -48,6 +49,45 @@ func (b *MultiBufferPool) Put(data *bytes.Buffer) bool {
 	return false
 }
 
+// Stats returns stats of each pool by bufSize
+func (b *MultiBufferPool) Stats() map[int]Stats {
+	stats := make(map[int]Stats, len(b.pools))
+	for _, p := range b.pools {
+		stats[p.bufSize] = p.Stats()
+	}
+	return stats
+}
+
+// Trim trims each pool, returns the total number of released objects
+func (b *MultiBufferPool) Trim() int {
+	released := 0
+	for _, p := range b.pools {
+		released += p.Trim()
+	}
+	return released
+}
+
+// Close closes each pool, returns the first error
+func (b *MultiBufferPool) Close() error {
+	var err error
+	for _, p := range b.pools {
+		if e := p.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Drain waits until all Refs of each pool are released or ctx is done
+func (b *MultiBufferPool) Drain(ctx context.Context) error {
+	for _, p := range b.pools {
+		if err := p.Drain(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type multiBufferPoolOptionFunc func(*multiBufferPoolOption)
 
 type multiBufferPoolOption struct {
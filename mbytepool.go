@@ -1,6 +1,7 @@
 package bp
 
 import (
+	"context"
 	"sort"
 )
 
@@ -48,6 +49,45 @@ func (b *MultiBytePool) Put(data []byte) bool {
 	return false
 }
 
+// Stats returns stats of each pool by bufSize
+func (b *MultiBytePool) Stats() map[int]Stats {
+	stats := make(map[int]Stats, len(b.pools))
+	for _, p := range b.pools {
+		stats[p.bufSize] = p.Stats()
+	}
+	return stats
+}
+
+// Trim trims each pool, returns the total number of released objects
+func (b *MultiBytePool) Trim() int {
+	released := 0
+	for _, p := range b.pools {
+		released += p.Trim()
+	}
+	return released
+}
+
+// Close closes each pool, returns the first error
+func (b *MultiBytePool) Close() error {
+	var err error
+	for _, p := range b.pools {
+		if e := p.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Drain waits until all Refs of each pool are released or ctx is done
+func (b *MultiBytePool) Drain(ctx context.Context) error {
+	for _, p := range b.pools {
+		if err := p.Drain(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type multiBytePoolOptionFunc func(*multiBytePoolOption)
 
 type multiBytePoolOption struct {
@@ -78,9 +118,13 @@ func MultiBytePoolOption(funcs ...optionFunc) multiBytePoolOptionFunc {
 	}
 }
 
-func uniqBytepoolTuple(tuples []bytepoolTuple) []bytepoolTuple {
+// uniqBytepoolTuple removes tuples of same bufSize after rounded up to alignment
+func uniqBytepoolTuple(tuples []bytepoolTuple, alignment int) []bytepoolTuple {
 	uniq := make(map[int]bytepoolTuple)
 	for _, t := range tuples {
+		if 1 < alignment {
+			t.bufSize = alignUp(t.bufSize, alignment)
+		}
 		if _, ok := uniq[t.bufSize]; ok {
 			continue
 		}
@@ -99,8 +143,12 @@ func NewMultiBytePool(funcs ...multiBytePoolOptionFunc) *MultiBytePool {
 		fn(mOpt)
 	}
 
-	tuples := uniqBytepoolTuple(mOpt.tuples)
 	poolFuncs := mOpt.poolFuncs
+	opt := newOption()
+	for _, fn := range poolFuncs {
+		fn(opt)
+	}
+	tuples := uniqBytepoolTuple(mOpt.tuples, opt.alignment)
 
 	sort.Slice(tuples, func(a, b int) bool {
 		return tuples[a].bufSize < tuples[b].bufSize
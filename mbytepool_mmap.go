@@ -4,6 +4,7 @@
 package bp
 
 import (
+	"context"
 	"sort"
 )
 
@@ -51,6 +52,63 @@ func (b *MultiMmapBytePool) Put(data []byte) bool {
 	return false
 }
 
+// Stats returns stats of each pool by bufSize
+func (b *MultiMmapBytePool) Stats() map[int]Stats {
+	stats := make(map[int]Stats, len(b.pools))
+	for _, p := range b.pools {
+		stats[p.bufSize] = p.Stats()
+	}
+	return stats
+}
+
+// Modes returns mmap features in use of each pool by bufSize
+func (b *MultiMmapBytePool) Modes() map[int]MmapMode {
+	modes := make(map[int]MmapMode, len(b.pools))
+	for _, p := range b.pools {
+		modes[p.bufSize] = p.Mode()
+	}
+	return modes
+}
+
+// Slabs returns occupancy of slabs of each pool by bufSize
+func (b *MultiMmapBytePool) Slabs() map[int][]SlabInfo {
+	slabs := make(map[int][]SlabInfo, len(b.pools))
+	for _, p := range b.pools {
+		slabs[p.bufSize] = p.Slabs()
+	}
+	return slabs
+}
+
+// Trim trims each pool, returns the total number of released objects
+func (b *MultiMmapBytePool) Trim() int {
+	released := 0
+	for _, p := range b.pools {
+		released += p.Trim()
+	}
+	return released
+}
+
+// Close closes each pool, returns the first error
+func (b *MultiMmapBytePool) Close() error {
+	var err error
+	for _, p := range b.pools {
+		if e := p.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Drain waits until all Refs of each pool are released or ctx is done
+func (b *MultiMmapBytePool) Drain(ctx context.Context) error {
+	for _, p := range b.pools {
+		if err := p.Drain(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type multiMmapBytePoolOptionFunc func(*multiMmapBytePoolOption)
 
 type multiMmapBytePoolOption struct {
@@ -81,9 +139,13 @@ func MultiMmapBytePoolOption(funcs ...optionFunc) multiMmapBytePoolOptionFunc {
 	}
 }
 
-func uniqMmapBytepoolTuple(tuples []mmapBytepoolTuple) []mmapBytepoolTuple {
+// uniqMmapBytepoolTuple removes tuples of same bufSize after rounded up to alignment
+func uniqMmapBytepoolTuple(tuples []mmapBytepoolTuple, alignment int) []mmapBytepoolTuple {
 	uniq := make(map[int]mmapBytepoolTuple)
 	for _, t := range tuples {
+		if 1 < alignment {
+			t.bufSize = alignUp(t.bufSize, alignment)
+		}
 		if _, ok := uniq[t.bufSize]; ok {
 			continue
 		}
@@ -102,8 +164,12 @@ func NewMultiMmapBytePool(funcs ...multiMmapBytePoolOptionFunc) *MultiMmapBytePo
 		fn(mOpt)
 	}
 
-	tuples := uniqMmapBytepoolTuple(mOpt.tuples)
 	poolFuncs := mOpt.poolFuncs
+	opt := newOption()
+	for _, fn := range poolFuncs {
+		fn(opt)
+	}
+	tuples := uniqMmapBytepoolTuple(mOpt.tuples, opt.alignment)
 
 	pools := make([]*MmapBytePool, len(tuples))
 	for i, t := range tuples {
@@ -49,6 +49,23 @@ func TestMultiMmapBytePoolNew(t *testing.T) {
 			}
 		}
 	})
+	t.Run("alignment", func(tt *testing.T) {
+		mp := NewMultiMmapBytePool(
+			MultiMmapBytePoolSize(10, 100),
+			MultiMmapBytePoolSize(10, 200),
+			MultiMmapBytePoolSize(10, 1000),
+			MultiMmapBytePoolOption(Alignment(512)),
+		)
+		if len(mp.pools) != 2 {
+			tt.Errorf("100 and 200 are same size after aligned: %d pools", len(mp.pools))
+		}
+		if len(mp.Stats()) != len(mp.pools) {
+			tt.Errorf("stats of each pool: %d", len(mp.Stats()))
+		}
+		if mp.pools[0].bufSize != 512 || mp.pools[1].bufSize != 1024 {
+			tt.Errorf("aligned bufSize %d %d", mp.pools[0].bufSize, mp.pools[1].bufSize)
+		}
+	})
 }
 
 func TestMultiMmapBytePoolPutGet(t *testing.T) {
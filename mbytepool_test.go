@@ -46,6 +46,23 @@ func TestMultiBytePoolNew(t *testing.T) {
 			}
 		}
 	})
+	t.Run("alignment", func(tt *testing.T) {
+		mp := NewMultiBytePool(
+			MultiBytePoolSize(10, 100),
+			MultiBytePoolSize(10, 200),
+			MultiBytePoolSize(10, 1000),
+			MultiBytePoolOption(Alignment(512)),
+		)
+		if len(mp.pools) != 2 {
+			tt.Errorf("100 and 200 are same size after aligned: %d pools", len(mp.pools))
+		}
+		if len(mp.Stats()) != len(mp.pools) {
+			tt.Errorf("stats of each pool: %d", len(mp.Stats()))
+		}
+		if mp.pools[0].bufSize != 512 || mp.pools[1].bufSize != 1024 {
+			tt.Errorf("aligned bufSize %d %d", mp.pools[0].bufSize, mp.pools[1].bufSize)
+		}
+	})
 }
 
 func TestMultiBytePoolPutGet(t *testing.T) {
@@ -0,0 +1,323 @@
+package bp
+
+import (
+	"bufio"
+	"errors"
+	"image"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	metricsContentType string = "text/plain; version=0.0.4; charset=utf-8"
+	metricsPrefix      string = "bp_"
+)
+
+var (
+	ErrMetricsAlreadyRegistered = errors.New("pool name already registered")
+	ErrMetricsNotSupported      = errors.New("pool does not provide Stats()")
+)
+
+type metricsLabel struct {
+	name, value string
+}
+
+type metricsLabeler interface {
+	metricsLabels() []metricsLabel
+}
+
+type labeledStats struct {
+	labels []metricsLabel
+	stats  Stats
+}
+
+type metricsCollectFunc func() []labeledStats
+
+type poolStatsGetter interface {
+	Stats() Stats
+}
+
+type multiSizeStatsGetter interface {
+	Stats() map[int]Stats
+}
+
+type multiRectStatsGetter interface {
+	Stats() map[image.Rectangle]Stats
+}
+
+type metricsValueFunc func(Stats) (extra []metricsLabel, value float64)
+
+type metricsFamily struct {
+	name   string
+	help   string
+	typ    string
+	values []metricsValueFunc
+}
+
+func metricsValue(fn func(Stats) float64) metricsValueFunc {
+	return func(s Stats) ([]metricsLabel, float64) {
+		return nil, fn(s)
+	}
+}
+
+func metricsLabeledValue(name, value string, fn func(Stats) float64) metricsValueFunc {
+	return func(s Stats) ([]metricsLabel, float64) {
+		return []metricsLabel{{name, value}}, fn(s)
+	}
+}
+
+var metricsFamilies = []metricsFamily{
+	{
+		name: "pool_len",
+		help: "Number of objects retained in pool.",
+		typ:  "gauge",
+		values: []metricsValueFunc{
+			metricsValue(func(s Stats) float64 { return float64(s.Len) }),
+		},
+	},
+	{
+		name: "pool_cap",
+		help: "Capacity of pool.",
+		typ:  "gauge",
+		values: []metricsValueFunc{
+			metricsValue(func(s Stats) float64 { return float64(s.Cap) }),
+		},
+	},
+	{
+		name: "get_total",
+		help: "Number of Get, result=hit is reused from pool, result=miss is allocated and result=canceled is canceled by context.",
+		typ:  "counter",
+		values: []metricsValueFunc{
+			metricsLabeledValue("result", "hit", func(s Stats) float64 { return float64(s.GetHit) }),
+			metricsLabeledValue("result", "miss", func(s Stats) float64 { return float64(s.GetMiss) }),
+			metricsLabeledValue("result", "canceled", func(s Stats) float64 { return float64(s.GetCanceled) }),
+		},
+	},
+	{
+		name: "put_accepted_total",
+		help: "Number of Put returned to pool.",
+		typ:  "counter",
+		values: []metricsValueFunc{
+			metricsValue(func(s Stats) float64 { return float64(s.PutAccepted) }),
+		},
+	},
+	{
+		name: "put_discarded_total",
+		help: "Number of Put discarded by reason.",
+		typ:  "counter",
+		values: []metricsValueFunc{
+			metricsLabeledValue("reason", "too_big", func(s Stats) float64 { return float64(s.PutDiscardTooBig) }),
+			metricsLabeledValue("reason", "too_small", func(s Stats) float64 { return float64(s.PutDiscardTooSmall) }),
+			metricsLabeledValue("reason", "full", func(s Stats) float64 { return float64(s.PutDiscardFull) }),
+			metricsLabeledValue("reason", "rejected", func(s Stats) float64 { return float64(s.PutDiscardRejected) }),
+			metricsLabeledValue("reason", "duplicate", func(s Stats) float64 { return float64(s.PutDiscardDuplicate) }),
+			metricsLabeledValue("reason", "foreign", func(s Stats) float64 { return float64(s.PutDiscardForeign) }),
+			metricsLabeledValue("reason", "budget", func(s Stats) float64 { return float64(s.PutDiscardBudget) }),
+			metricsLabeledValue("reason", "closed", func(s Stats) float64 { return float64(s.PutDiscardClosed) }),
+		},
+	},
+	{
+		name: "ref_acquired_total",
+		help: "Number of Ref acquired by GetRef.",
+		typ:  "counter",
+		values: []metricsValueFunc{
+			metricsValue(func(s Stats) float64 { return float64(s.RefAcquired) }),
+		},
+	},
+	{
+		name: "ref_released_total",
+		help: "Number of Ref released, via=release is Release() and via=finalizer is released by GC.",
+		typ:  "counter",
+		values: []metricsValueFunc{
+			metricsLabeledValue("via", "release", func(s Stats) float64 { return float64(s.RefReleased) }),
+			metricsLabeledValue("via", "finalizer", func(s Stats) float64 { return float64(s.RefFinalized) }),
+		},
+	},
+	{
+		name: "ref_outstanding",
+		help: "Number of Ref not yet released.",
+		typ:  "gauge",
+		values: []metricsValueFunc{
+			metricsValue(func(s Stats) float64 { return float64(s.RefOutstanding) }),
+		},
+	},
+	{
+		name: "trimmed_total",
+		help: "Number of pooled objects released by Trim.",
+		typ:  "counter",
+		values: []metricsValueFunc{
+			metricsValue(func(s Stats) float64 { return float64(s.Trimmed) }),
+		},
+	},
+}
+
+// Registry renders Stats of registered pools in Prometheus text exposition format.
+type Registry struct {
+	mutex *sync.RWMutex
+	names []string
+	pools map[string]metricsCollectFunc
+}
+
+func (r *Registry) Register(name string, pool interface{}) error {
+	collect, ok := metricsCollectFuncOf(pool)
+	if ok != true {
+		return ErrMetricsNotSupported
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.pools[name]; exists {
+		return ErrMetricsAlreadyRegistered
+	}
+	r.pools[name] = collect
+	r.names = append(r.names, name)
+	sort.Strings(r.names)
+	return nil
+}
+
+func (r *Registry) Unregister(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.pools[name]; exists != true {
+		return
+	}
+	delete(r.pools, name)
+	for i, n := range r.names {
+		if n == name {
+			r.names = append(r.names[:i], r.names[i+1:]...)
+			break
+		}
+	}
+}
+
+func (r *Registry) collect() ([]string, map[string][]labeledStats) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	names := make([]string, len(r.names))
+	copy(names, r.names)
+	stats := make(map[string][]labeledStats, len(r.pools))
+	for _, name := range names {
+		stats[name] = r.pools[name]()
+	}
+	return names, stats
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", metricsContentType)
+
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+
+	names, stats := r.collect()
+	for _, f := range metricsFamilies {
+		metricName := metricsPrefix + f.name
+		bw.WriteString("# HELP " + metricName + " " + f.help + "\n")
+		bw.WriteString("# TYPE " + metricName + " " + f.typ + "\n")
+		for _, name := range names {
+			for _, ls := range stats[name] {
+				for _, fn := range f.values {
+					extra, value := fn(ls.stats)
+					labels := make([]metricsLabel, 0, 1+len(ls.labels)+len(extra))
+					labels = append(labels, metricsLabel{"pool", name})
+					labels = append(labels, ls.labels...)
+					labels = append(labels, extra...)
+					writeMetricsLine(bw, metricName, labels, value)
+				}
+			}
+		}
+	}
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		mutex: new(sync.RWMutex),
+		names: make([]string, 0),
+		pools: make(map[string]metricsCollectFunc),
+	}
+}
+
+func writeMetricsLine(w *bufio.Writer, name string, labels []metricsLabel, value float64) {
+	w.WriteString(name)
+	w.WriteString("{")
+	for i, l := range labels {
+		if 0 < i {
+			w.WriteString(",")
+		}
+		w.WriteString(l.name)
+		w.WriteString(`="`)
+		w.WriteString(escapeMetricsLabelValue(l.value))
+		w.WriteString(`"`)
+	}
+	w.WriteString("} ")
+	w.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	w.WriteString("\n")
+}
+
+var metricsLabelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeMetricsLabelValue(v string) string {
+	return metricsLabelValueReplacer.Replace(v)
+}
+
+func metricsCollectFuncOf(pool interface{}) (metricsCollectFunc, bool) {
+	switch p := pool.(type) {
+	case poolStatsGetter:
+		return func() []labeledStats {
+			var labels []metricsLabel
+			if l, ok := pool.(metricsLabeler); ok {
+				labels = l.metricsLabels()
+			}
+			return []labeledStats{{labels, p.Stats()}}
+		}, true
+	case multiSizeStatsGetter:
+		return func() []labeledStats {
+			stats := p.Stats()
+			sizes := make([]int, 0, len(stats))
+			for size := range stats {
+				sizes = append(sizes, size)
+			}
+			sort.Ints(sizes)
+
+			values := make([]labeledStats, len(sizes))
+			for i, size := range sizes {
+				values[i] = labeledStats{bufSizeMetricsLabels(size), stats[size]}
+			}
+			return values
+		}, true
+	case multiRectStatsGetter:
+		return func() []labeledStats {
+			stats := p.Stats()
+			rects := make([]image.Rectangle, 0, len(stats))
+			for rect := range stats {
+				rects = append(rects, rect)
+			}
+			sort.Slice(rects, func(a, b int) bool {
+				if rects[a].Dx() == rects[b].Dx() {
+					return rects[a].Dy() < rects[b].Dy()
+				}
+				return rects[a].Dx() < rects[b].Dx()
+			})
+
+			values := make([]labeledStats, len(rects))
+			for i, rect := range rects {
+				values[i] = labeledStats{rectMetricsLabels(rect), stats[rect]}
+			}
+			return values
+		}, true
+	}
+	return nil, false
+}
+
+func bufSizeMetricsLabels(bufSize int) []metricsLabel {
+	return []metricsLabel{{"bufSize", strconv.Itoa(bufSize)}}
+}
+
+func rectMetricsLabels(rect image.Rectangle) []metricsLabel {
+	return []metricsLabel{{"rect", rect.String()}}
+}
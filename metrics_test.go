@@ -0,0 +1,81 @@
+package bp
+
+import (
+	"image"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Run("register", func(tt *testing.T) {
+		r := NewRegistry()
+		if err := r.Register("byte", NewBytePool(10, 8)); err != nil {
+			tt.Errorf("no error: %+v", err)
+		}
+		if err := r.Register("byte", NewBytePool(10, 8)); err != ErrMetricsAlreadyRegistered {
+			tt.Errorf("duplicate name: %+v", err)
+		}
+		if err := r.Register("invalid", "string"); err != ErrMetricsNotSupported {
+			tt.Errorf("not supported: %+v", err)
+		}
+		r.Unregister("byte")
+		if err := r.Register("byte", NewBytePool(10, 8)); err != nil {
+			tt.Errorf("unregistered: %+v", err)
+		}
+	})
+	t.Run("exposition", func(tt *testing.T) {
+		p := NewBytePool(10, 8)
+		p.Put(p.Get())
+		p.Get()
+		p.Put(make([]byte, 100))
+
+		mp := NewMultiBytePool(
+			MultiBytePoolSize(10, 16),
+			MultiBytePoolSize(10, 32),
+		)
+		mp.Put(mp.Get(20))
+
+		ip := NewMultiImageRGBAPool(
+			MultiImagePoolSize(10, image.Rect(0, 0, 10, 10)),
+		)
+		ip.GetRef(image.Rect(0, 0, 5, 5))
+
+		r := NewRegistry()
+		r.Register("bytes", p)
+		r.Register("multi", mp)
+		r.Register("img", ip)
+		r.Register("timer", NewTimerPool(10))
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+		if ct := rec.Header().Get("Content-Type"); strings.HasPrefix(ct, "text/plain; version=0.0.4") != true {
+			tt.Errorf("content-type = %s", ct)
+		}
+		body, _ := io.ReadAll(rec.Body)
+		out := string(body)
+		expects := []string{
+			"# TYPE bp_get_total counter\n",
+			"# TYPE bp_pool_len gauge\n",
+			`bp_get_total{pool="bytes",bufSize="8",result="hit"} 1` + "\n",
+			`bp_get_total{pool="bytes",bufSize="8",result="miss"} 1` + "\n",
+			`bp_put_discarded_total{pool="bytes",bufSize="8",reason="too_big"} 1` + "\n",
+			`bp_put_accepted_total{pool="multi",bufSize="16"} 0` + "\n",
+			`bp_put_accepted_total{pool="multi",bufSize="32"} 1` + "\n",
+			`bp_ref_outstanding{pool="img",rect="(0,0)-(10,10)"} 1` + "\n",
+			`bp_pool_cap{pool="timer"} 10` + "\n",
+		}
+		for _, e := range expects {
+			if strings.Contains(out, e) != true {
+				tt.Errorf("expect %q in\n%s", e, out)
+			}
+		}
+	})
+	t.Run("escape", func(tt *testing.T) {
+		if v := escapeMetricsLabelValue("a\"b\\c\nd"); v != `a\"b\\c\nd` {
+			tt.Errorf("escaped = %s", v)
+		}
+	})
+}
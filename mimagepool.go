@@ -1,45 +1,49 @@
 package bp
 
 import (
+	"context"
 	"image"
+	"image/color"
 	"sort"
 )
 
-type MultiImageRGBAPool struct {
-	tuples []imagepoolTuple
-	pools  []*ImageRGBAPool
+// rectImagePool is pool of Multi pools, it builds images of any rect that fits in pool rect
+type rectImagePool[R any] interface {
+	Put([]byte) bool
+	Stats() Stats
+	Trim() int
+	Close() error
+	Drain(context.Context) error
+	fits(image.Rectangle) bool
+	getRef(image.Rectangle) R
+	newRef(image.Rectangle) R
 }
 
-func (b *MultiImageRGBAPool) find(r image.Rectangle) (*ImageRGBAPool, bool) {
-	if r.Empty() {
-		return nil, false
-	}
+// multiImagePool selects the smallest pool that fits rect, R is Ref built by pool P
+type multiImagePool[R any, P rectImagePool[R]] struct {
+	tuples []imagepoolTuple
+	pools  []P
+}
 
-	for i, t := range b.tuples {
-		if rectIn(t.rect, r) {
-			return b.pools[i], true
+func (b *multiImagePool[R, P]) find(r image.Rectangle) (P, bool) {
+	for _, p := range b.pools {
+		if p.fits(r) {
+			return p, true
 		}
 	}
-	return nil, false
+	var none P
+	return none, false
 }
 
-func (b *MultiImageRGBAPool) GetRef(r image.Rectangle) *ImageRGBARef {
+func (b *multiImagePool[R, P]) GetRef(r image.Rectangle) R {
 	if pool, ok := b.find(r); ok {
-		ref := pool.GetRef()
-		b.adjust(ref, r)
-		return ref
+		return pool.getRef(r)
 	}
-
-	pool := &ImageRGBAPool{}
-	pool.init(r)
-
-	pix := make([]uint8, pool.length)
-	ref := pool.createImageRGBARef(pix, b.pools[len(b.pools)-1])
-	b.adjust(ref, r)
-	return ref
+	// not pooled, Release puts it back to the largest pool
+	return b.pools[len(b.pools)-1].newRef(r)
 }
 
-func (b *MultiImageRGBAPool) Put(pix []uint8, r image.Rectangle) bool {
+func (b *multiImagePool[R, P]) Put(pix []uint8, r image.Rectangle) bool {
 	if pool, ok := b.find(r); ok {
 		return pool.Put(pix)
 	}
@@ -47,17 +51,46 @@ func (b *MultiImageRGBAPool) Put(pix []uint8, r image.Rectangle) bool {
 	return false
 }
 
-func (b *MultiImageRGBAPool) adjust(ref *ImageRGBARef, r image.Rectangle) {
-	ref.Img.Rect = r
-	ref.Img.Stride = imageRGBAStride(r)
+// Stats returns stats of each pool by rect
+func (b *multiImagePool[R, P]) Stats() map[image.Rectangle]Stats {
+	stats := make(map[image.Rectangle]Stats, len(b.pools))
+	for i, t := range b.tuples {
+		stats[t.rect] = b.pools[i].Stats()
+	}
+	return stats
 }
 
-type MultiImageNRGBAPool struct {
-	tuples []imagepoolTuple
-	pools  []*ImageNRGBAPool
+// Trim trims each pool, returns the total number of released objects
+func (b *multiImagePool[R, P]) Trim() int {
+	released := 0
+	for _, p := range b.pools {
+		released += p.Trim()
+	}
+	return released
 }
 
-func NewMultiImageNRGBAPool(funcs ...multiImageBufferPoolOptionFunc) *MultiImageNRGBAPool {
+// Close closes each pool, returns the first error
+func (b *multiImagePool[R, P]) Close() error {
+	var err error
+	for _, p := range b.pools {
+		if e := p.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Drain waits until all Refs of each pool are released or ctx is done
+func (b *multiImagePool[R, P]) Drain(ctx context.Context) error {
+	for _, p := range b.pools {
+		if err := p.Drain(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newMultiImagePool[R any, P rectImagePool[R]](funcs []multiImageBufferPoolOptionFunc, newPool func(int, image.Rectangle, ...optionFunc) P) multiImagePool[R, P] {
 	mOpt := newMultiImageBufferPoolOption()
 	for _, fn := range funcs {
 		fn(mOpt)
@@ -66,132 +99,142 @@ func NewMultiImageNRGBAPool(funcs ...multiImageBufferPoolOptionFunc) *MultiImage
 	tuples := uniqImagepoolTuple(mOpt.tuples)
 	sortTuples(tuples)
 
-	pools := make([]*ImageNRGBAPool, len(tuples))
+	pools := make([]P, len(tuples))
 	for i, t := range tuples {
-		pools[i] = NewImageNRGBAPool(t.poolSize, t.rect, mOpt.poolFuncs...)
+		pools[i] = newPool(t.poolSize, t.rect, mOpt.poolFuncs...)
 	}
-	return &MultiImageNRGBAPool{
+	return multiImagePool[R, P]{
 		tuples: tuples,
 		pools:  pools,
 	}
 }
 
-func (b *MultiImageNRGBAPool) find(r image.Rectangle) (*ImageNRGBAPool, bool) {
-	if r.Empty() {
-		return nil, false
-	}
+type MultiImageRGBAPool struct {
+	multiImagePool[*ImageRGBARef, *ImageRGBAPool]
+}
 
-	for i, t := range b.tuples {
-		if rectIn(t.rect, r) {
-			return b.pools[i], true
-		}
-	}
-	return nil, false
+type MultiImageNRGBAPool struct {
+	multiImagePool[*ImageNRGBARef, *ImageNRGBAPool]
 }
 
-func (b *MultiImageNRGBAPool) GetRef(r image.Rectangle) *ImageNRGBARef {
-	if pool, ok := b.find(r); ok {
-		ref := pool.GetRef()
-		b.adjust(ref, r)
-		return ref
+func NewMultiImageNRGBAPool(funcs ...multiImageBufferPoolOptionFunc) *MultiImageNRGBAPool {
+	return &MultiImageNRGBAPool{
+		newMultiImagePool[*ImageNRGBARef](funcs, NewImageNRGBAPool),
 	}
+}
 
-	pool := &ImageNRGBAPool{}
-	pool.init(r)
-
-	pix := make([]uint8, pool.length)
-	ref := pool.createImageNRGBARef(pix, b.pools[len(b.pools)-1])
-	b.adjust(ref, r)
-	return ref
+type MultiImageGrayPool struct {
+	multiImagePool[*ImageGrayRef, *ImageGrayPool]
 }
 
-func (b *MultiImageNRGBAPool) Put(pix []uint8, r image.Rectangle) bool {
-	if pool, ok := b.find(r); ok {
-		return pool.Put(pix)
+func NewMultiImageGrayPool(funcs ...multiImageBufferPoolOptionFunc) *MultiImageGrayPool {
+	return &MultiImageGrayPool{
+		newMultiImagePool[*ImageGrayRef](funcs, NewImageGrayPool),
 	}
-	// discard
-	return false
 }
 
-func (b *MultiImageNRGBAPool) adjust(ref *ImageNRGBARef, r image.Rectangle) {
-	ref.Img.Rect = r
-	ref.Img.Stride = imageRGBAStride(r)
+type MultiImageGray16Pool struct {
+	multiImagePool[*ImageGray16Ref, *ImageGray16Pool]
 }
 
-type MultiImageYCbCrPool struct {
-	tuples []imagepoolTuple
-	pools  []*ImageYCbCrPool
-	sample image.YCbCrSubsampleRatio
+func NewMultiImageGray16Pool(funcs ...multiImageBufferPoolOptionFunc) *MultiImageGray16Pool {
+	return &MultiImageGray16Pool{
+		newMultiImagePool[*ImageGray16Ref](funcs, NewImageGray16Pool),
+	}
 }
 
-func NewMultiImageYCbCrPool(sample image.YCbCrSubsampleRatio, funcs ...multiImageBufferPoolOptionFunc) *MultiImageYCbCrPool {
-	mOpt := newMultiImageBufferPoolOption()
-	for _, fn := range funcs {
-		fn(mOpt)
+type MultiImageAlphaPool struct {
+	multiImagePool[*ImageAlphaRef, *ImageAlphaPool]
+}
+
+func NewMultiImageAlphaPool(funcs ...multiImageBufferPoolOptionFunc) *MultiImageAlphaPool {
+	return &MultiImageAlphaPool{
+		newMultiImagePool[*ImageAlphaRef](funcs, NewImageAlphaPool),
 	}
+}
 
-	tuples := uniqImagepoolTuple(mOpt.tuples)
-	sortTuples(tuples)
+type MultiImageAlpha16Pool struct {
+	multiImagePool[*ImageAlpha16Ref, *ImageAlpha16Pool]
+}
 
-	pools := make([]*ImageYCbCrPool, len(tuples))
-	for i, t := range tuples {
-		pools[i] = NewImageYCbCrPool(t.poolSize, t.rect, sample, mOpt.poolFuncs...)
-	}
-	return &MultiImageYCbCrPool{
-		tuples: tuples,
-		pools:  pools,
-		sample: sample,
+func NewMultiImageAlpha16Pool(funcs ...multiImageBufferPoolOptionFunc) *MultiImageAlpha16Pool {
+	return &MultiImageAlpha16Pool{
+		newMultiImagePool[*ImageAlpha16Ref](funcs, NewImageAlpha16Pool),
 	}
 }
 
-func (b *MultiImageYCbCrPool) find(r image.Rectangle) (*ImageYCbCrPool, bool) {
-	for i, t := range b.tuples {
-		if rectIn(t.rect, r) {
-			return b.pools[i], true
-		}
+type MultiImageRGBA64Pool struct {
+	multiImagePool[*ImageRGBA64Ref, *ImageRGBA64Pool]
+}
+
+func NewMultiImageRGBA64Pool(funcs ...multiImageBufferPoolOptionFunc) *MultiImageRGBA64Pool {
+	return &MultiImageRGBA64Pool{
+		newMultiImagePool[*ImageRGBA64Ref](funcs, NewImageRGBA64Pool),
 	}
-	return nil, false
 }
 
-func (b *MultiImageYCbCrPool) GetRef(r image.Rectangle) *ImageYCbCrRef {
-	if pool, ok := b.find(r); ok {
-		ref := pool.GetRef()
-		b.adjust(ref, r)
-		return ref
+type MultiImageNRGBA64Pool struct {
+	multiImagePool[*ImageNRGBA64Ref, *ImageNRGBA64Pool]
+}
+
+func NewMultiImageNRGBA64Pool(funcs ...multiImageBufferPoolOptionFunc) *MultiImageNRGBA64Pool {
+	return &MultiImageNRGBA64Pool{
+		newMultiImagePool[*ImageNRGBA64Ref](funcs, NewImageNRGBA64Pool),
 	}
+}
 
-	pool := &ImageYCbCrPool{}
-	pool.init(r, b.sample)
+type MultiImageCMYKPool struct {
+	multiImagePool[*ImageCMYKRef, *ImageCMYKPool]
+}
 
-	pix := make([]uint8, pool.length)
-	ref := pool.createImageYCbCrRef(pix, b.pools[len(b.pools)-1])
-	b.adjust(ref, r)
-	return ref
+func NewMultiImageCMYKPool(funcs ...multiImageBufferPoolOptionFunc) *MultiImageCMYKPool {
+	return &MultiImageCMYKPool{
+		newMultiImagePool[*ImageCMYKRef](funcs, NewImageCMYKPool),
+	}
 }
 
-func (b *MultiImageYCbCrPool) Put(pix []uint8, r image.Rectangle) bool {
-	if pool, ok := b.find(r); ok {
-		return pool.Put(pix)
+type MultiImagePalettedPool struct {
+	multiImagePool[*ImagePalettedRef, *ImagePalettedPool]
+}
+
+// NewMultiImagePalettedPool returns pools of image.Paletted, palette is shared by images of GetRef.
+func NewMultiImagePalettedPool(palette color.Palette, funcs ...multiImageBufferPoolOptionFunc) *MultiImagePalettedPool {
+	return &MultiImagePalettedPool{
+		newMultiImagePool[*ImagePalettedRef](funcs, func(poolSize int, rect image.Rectangle, funcs ...optionFunc) *ImagePalettedPool {
+			return NewImagePalettedPool(poolSize, rect, palette, funcs...)
+		}),
 	}
-	// discard
-	return false
 }
 
-func (b *MultiImageYCbCrPool) adjust(ref *ImageYCbCrRef, r image.Rectangle) {
-	w, h := r.Dx(), r.Dy()
-	cw, ch := yuvSize(r, b.sample)
+// GetRefWithPalette returns image with palette supplied by caller
+func (b *MultiImagePalettedPool) GetRefWithPalette(r image.Rectangle, palette color.Palette) *ImagePalettedRef {
+	ref := b.GetRef(r)
+	ref.Img.Palette = palette
+	return ref
+}
+
+type MultiImageYCbCrPool struct {
+	multiImagePool[*ImageYCbCrRef, *ImageYCbCrPool]
+}
 
-	i0 := (w * h) + (0 * cw * ch)
-	i1 := (w * h) + (1 * cw * ch)
-	i2 := (w * h) + (2 * cw * ch)
+func NewMultiImageYCbCrPool(sample image.YCbCrSubsampleRatio, funcs ...multiImageBufferPoolOptionFunc) *MultiImageYCbCrPool {
+	return &MultiImageYCbCrPool{
+		newMultiImagePool[*ImageYCbCrRef](funcs, func(poolSize int, rect image.Rectangle, funcs ...optionFunc) *ImageYCbCrPool {
+			return NewImageYCbCrPool(poolSize, rect, sample, funcs...)
+		}),
+	}
+}
 
-	ref.Img.Y = ref.pix[0:i0:i0]
-	ref.Img.Cb = ref.pix[i0:i1:i1]
-	ref.Img.Cr = ref.pix[i1:i2:i2]
+type MultiImageNYCbCrAPool struct {
+	multiImagePool[*ImageNYCbCrARef, *ImageNYCbCrAPool]
+}
 
-	ref.Img.Rect = r
-	ref.Img.YStride = w
-	ref.Img.CStride = cw
+func NewMultiImageNYCbCrAPool(sample image.YCbCrSubsampleRatio, funcs ...multiImageBufferPoolOptionFunc) *MultiImageNYCbCrAPool {
+	return &MultiImageNYCbCrAPool{
+		newMultiImagePool[*ImageNYCbCrARef](funcs, func(poolSize int, rect image.Rectangle, funcs ...optionFunc) *ImageNYCbCrAPool {
+			return NewImageNYCbCrAPool(poolSize, rect, sample, funcs...)
+		}),
+	}
 }
 
 type multiImageBufferPoolOptionFunc func(*multiImageBufferPoolOption)
@@ -250,21 +293,8 @@ func sortTuples(tuples []imagepoolTuple) {
 }
 
 func NewMultiImageRGBAPool(funcs ...multiImageBufferPoolOptionFunc) *MultiImageRGBAPool {
-	mOpt := newMultiImageBufferPoolOption()
-	for _, fn := range funcs {
-		fn(mOpt)
-	}
-
-	tuples := uniqImagepoolTuple(mOpt.tuples)
-	sortTuples(tuples)
-
-	pools := make([]*ImageRGBAPool, len(tuples))
-	for i, t := range tuples {
-		pools[i] = NewImageRGBAPool(t.poolSize, t.rect, mOpt.poolFuncs...)
-	}
 	return &MultiImageRGBAPool{
-		tuples: tuples,
-		pools:  pools,
+		newMultiImagePool[*ImageRGBARef](funcs, NewImageRGBAPool),
 	}
 }
 
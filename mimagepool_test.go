@@ -3,7 +3,10 @@ package bp
 import (
 	"fmt"
 	"image"
+	"image/color"
+	"image/color/palette"
 	"testing"
+	"time"
 )
 
 func TestMultiImageRGBAPoolNew(t *testing.T) {
@@ -411,3 +414,360 @@ func TestMultiImageYCbCrPoolPutGet(t *testing.T) {
 		}
 	})
 }
+
+func TestMultiImageYCbCrPoolSubsampleRatio(t *testing.T) {
+	samples := []image.YCbCrSubsampleRatio{
+		image.YCbCrSubsampleRatio444,
+		image.YCbCrSubsampleRatio422,
+		image.YCbCrSubsampleRatio420,
+		image.YCbCrSubsampleRatio440,
+		image.YCbCrSubsampleRatio411,
+		image.YCbCrSubsampleRatio410,
+	}
+	for _, sample := range samples {
+		t.Run(sample.String(), func(tt *testing.T) {
+			mp := NewMultiImageYCbCrPool(
+				sample,
+				MultiImagePoolSize(10, image.Rect(0, 0, 64, 36)),
+				MultiImagePoolSize(10, image.Rect(0, 0, 128, 72)),
+			)
+			for _, r := range []image.Rectangle{
+				image.Rect(0, 0, 64, 36),
+				image.Rect(1, 1, 65, 37), // odd origin needs larger chroma planes
+				image.Rect(3, 5, 50, 30),
+			} {
+				expect := image.NewYCbCr(r, sample)
+				ref := mp.GetRef(r)
+				img := ref.Image()
+				if len(img.Cb) != len(expect.Cb) || len(img.Cr) != len(expect.Cr) || img.CStride != expect.CStride {
+					tt.Errorf("%s chroma Cb=%d Cr=%d stride=%d", r, len(img.Cb), len(img.Cr), img.CStride)
+				}
+				if img.SubsampleRatio != sample {
+					tt.Errorf("%s sample = %s", r, img.SubsampleRatio)
+				}
+				last := img.COffset(r.Max.X-1, r.Max.Y-1)
+				img.Cr[last] = 0xff
+				if mp.Put(ref.pix, r) != true {
+					tt.Errorf("%s put", r)
+				}
+			}
+		})
+	}
+}
+
+func TestMultiImagePoolStdTypes(t *testing.T) {
+	tests := []struct {
+		name   string
+		get    func(image.Rectangle) (testImagePool, []byte, int, image.Rectangle, func() bool)
+		expect func(image.Rectangle) ([]byte, int)
+	}{
+		{
+			name: "Gray",
+			get: func(r image.Rectangle) (testImagePool, []byte, int, image.Rectangle, func() bool) {
+				mp := NewMultiImageGrayPool(
+					MultiImagePoolSize(10, image.Rect(0, 0, 640, 360)),
+					MultiImagePoolSize(10, image.Rect(0, 0, 1280, 720)),
+				)
+				ref := mp.GetRef(r)
+				return mp.pools[0], ref.Img.Pix, ref.Img.Stride, ref.Img.Rect, func() bool {
+					return mp.Put(ref.pix, r)
+				}
+			},
+			expect: func(r image.Rectangle) ([]byte, int) {
+				img := image.NewGray(r)
+				return img.Pix, img.Stride
+			},
+		},
+		{
+			name: "Gray16",
+			get: func(r image.Rectangle) (testImagePool, []byte, int, image.Rectangle, func() bool) {
+				mp := NewMultiImageGray16Pool(
+					MultiImagePoolSize(10, image.Rect(0, 0, 640, 360)),
+					MultiImagePoolSize(10, image.Rect(0, 0, 1280, 720)),
+				)
+				ref := mp.GetRef(r)
+				return mp.pools[0], ref.Img.Pix, ref.Img.Stride, ref.Img.Rect, func() bool {
+					return mp.Put(ref.pix, r)
+				}
+			},
+			expect: func(r image.Rectangle) ([]byte, int) {
+				img := image.NewGray16(r)
+				return img.Pix, img.Stride
+			},
+		},
+		{
+			name: "Alpha",
+			get: func(r image.Rectangle) (testImagePool, []byte, int, image.Rectangle, func() bool) {
+				mp := NewMultiImageAlphaPool(
+					MultiImagePoolSize(10, image.Rect(0, 0, 640, 360)),
+					MultiImagePoolSize(10, image.Rect(0, 0, 1280, 720)),
+				)
+				ref := mp.GetRef(r)
+				return mp.pools[0], ref.Img.Pix, ref.Img.Stride, ref.Img.Rect, func() bool {
+					return mp.Put(ref.pix, r)
+				}
+			},
+			expect: func(r image.Rectangle) ([]byte, int) {
+				img := image.NewAlpha(r)
+				return img.Pix, img.Stride
+			},
+		},
+		{
+			name: "Alpha16",
+			get: func(r image.Rectangle) (testImagePool, []byte, int, image.Rectangle, func() bool) {
+				mp := NewMultiImageAlpha16Pool(
+					MultiImagePoolSize(10, image.Rect(0, 0, 640, 360)),
+					MultiImagePoolSize(10, image.Rect(0, 0, 1280, 720)),
+				)
+				ref := mp.GetRef(r)
+				return mp.pools[0], ref.Img.Pix, ref.Img.Stride, ref.Img.Rect, func() bool {
+					return mp.Put(ref.pix, r)
+				}
+			},
+			expect: func(r image.Rectangle) ([]byte, int) {
+				img := image.NewAlpha16(r)
+				return img.Pix, img.Stride
+			},
+		},
+		{
+			name: "RGBA64",
+			get: func(r image.Rectangle) (testImagePool, []byte, int, image.Rectangle, func() bool) {
+				mp := NewMultiImageRGBA64Pool(
+					MultiImagePoolSize(10, image.Rect(0, 0, 640, 360)),
+					MultiImagePoolSize(10, image.Rect(0, 0, 1280, 720)),
+				)
+				ref := mp.GetRef(r)
+				return mp.pools[0], ref.Img.Pix, ref.Img.Stride, ref.Img.Rect, func() bool {
+					return mp.Put(ref.pix, r)
+				}
+			},
+			expect: func(r image.Rectangle) ([]byte, int) {
+				img := image.NewRGBA64(r)
+				return img.Pix, img.Stride
+			},
+		},
+		{
+			name: "NRGBA64",
+			get: func(r image.Rectangle) (testImagePool, []byte, int, image.Rectangle, func() bool) {
+				mp := NewMultiImageNRGBA64Pool(
+					MultiImagePoolSize(10, image.Rect(0, 0, 640, 360)),
+					MultiImagePoolSize(10, image.Rect(0, 0, 1280, 720)),
+				)
+				ref := mp.GetRef(r)
+				return mp.pools[0], ref.Img.Pix, ref.Img.Stride, ref.Img.Rect, func() bool {
+					return mp.Put(ref.pix, r)
+				}
+			},
+			expect: func(r image.Rectangle) ([]byte, int) {
+				img := image.NewNRGBA64(r)
+				return img.Pix, img.Stride
+			},
+		},
+		{
+			name: "CMYK",
+			get: func(r image.Rectangle) (testImagePool, []byte, int, image.Rectangle, func() bool) {
+				mp := NewMultiImageCMYKPool(
+					MultiImagePoolSize(10, image.Rect(0, 0, 640, 360)),
+					MultiImagePoolSize(10, image.Rect(0, 0, 1280, 720)),
+				)
+				ref := mp.GetRef(r)
+				return mp.pools[0], ref.Img.Pix, ref.Img.Stride, ref.Img.Rect, func() bool {
+					return mp.Put(ref.pix, r)
+				}
+			},
+			expect: func(r image.Rectangle) ([]byte, int) {
+				img := image.NewCMYK(r)
+				return img.Pix, img.Stride
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(tt *testing.T) {
+			r := image.Rect(10, 10, 110, 60) // 100x50 < pools[0]
+			pool, pix, stride, rect, put := tc.get(r)
+			expectPix, expectStride := tc.expect(r)
+			if len(pix) < len(expectPix) || stride != expectStride || rect.Eq(r) != true {
+				tt.Errorf("len=%d stride=%d rect=%s", len(pix), stride, rect)
+			}
+			if put() != true {
+				tt.Errorf("put ok")
+			}
+			if pool.Len() != 1 {
+				tt.Errorf("release pool[0]")
+			}
+		})
+	}
+}
+
+func TestMultiImageNYCbCrAPool(t *testing.T) {
+	for _, sample := range []image.YCbCrSubsampleRatio{image.YCbCrSubsampleRatio420, image.YCbCrSubsampleRatio422} {
+		t.Run(sample.String(), func(tt *testing.T) {
+			mp := NewMultiImageNYCbCrAPool(
+				sample,
+				MultiImagePoolSize(10, image.Rect(0, 0, 64, 36)),
+				MultiImagePoolSize(10, image.Rect(0, 0, 128, 72)),
+			)
+			for _, r := range []image.Rectangle{
+				image.Rect(0, 0, 64, 36),
+				image.Rect(1, 1, 65, 37),
+				image.Rect(3, 5, 50, 30),
+				image.Rect(0, 0, 200, 100), // larger than pools
+			} {
+				expect := image.NewNYCbCrA(r, sample)
+				ref := mp.GetRef(r)
+				img := ref.Image()
+				if len(img.Cr) != len(expect.Cr) || len(img.A) != len(expect.A) || img.AStride != expect.AStride {
+					tt.Errorf("%s Cr=%d A=%d stride=%d", r, len(img.Cr), len(img.A), img.AStride)
+				}
+				img.A[img.AOffset(r.Max.X-1, r.Max.Y-1)] = 0xff
+				ref.Release()
+			}
+			// pools[0] reuses released buffer, pools[1] keeps odd origin and larger pix
+			if mp.pools[0].Len() != 1 || mp.pools[1].Len() != 2 {
+				tt.Errorf("released len = %d, %d", mp.pools[0].Len(), mp.pools[1].Len())
+			}
+		})
+	}
+}
+
+func TestMultiImageYCbCrPoolEmptyRect(t *testing.T) {
+	r := image.Rect(10, 10, 10, 10)
+	t.Run("ycbcr", func(tt *testing.T) {
+		mp := NewMultiImageYCbCrPool(
+			image.YCbCrSubsampleRatio420,
+			MultiImagePoolSize(10, image.Rect(0, 0, 16, 16)),
+		)
+		ref := mp.GetRef(r)
+		if s := mp.pools[0].Stats(); s.GetHit+s.GetMiss != 0 {
+			tt.Errorf("empty rect is not pooled: %+v", s)
+		}
+		if len(ref.Image().Y) != 0 {
+			tt.Errorf("empty image: Y=%d", len(ref.Image().Y))
+		}
+		if mp.Put(ref.pix, r) {
+			tt.Errorf("empty rect is discarded")
+		}
+	})
+	t.Run("nycbcra", func(tt *testing.T) {
+		mp := NewMultiImageNYCbCrAPool(
+			image.YCbCrSubsampleRatio420,
+			MultiImagePoolSize(10, image.Rect(0, 0, 16, 16)),
+		)
+		ref := mp.GetRef(r)
+		if s := mp.pools[0].Stats(); s.GetHit+s.GetMiss != 0 {
+			tt.Errorf("empty rect is not pooled: %+v", s)
+		}
+		if len(ref.Image().A) != 0 {
+			tt.Errorf("empty image: A=%d", len(ref.Image().A))
+		}
+		if mp.Put(ref.pix, r) {
+			tt.Errorf("empty rect is discarded")
+		}
+	})
+}
+
+func TestMultiImagePalettedPool(t *testing.T) {
+	mp := NewMultiImagePalettedPool(
+		palette.Plan9,
+		MultiImagePoolSize(10, image.Rect(0, 0, 64, 36)),
+		MultiImagePoolSize(10, image.Rect(0, 0, 128, 72)),
+	)
+	r := image.Rect(10, 10, 60, 40)
+	d1 := mp.GetRef(r)
+	if len(d1.Image().Palette) != len(palette.Plan9) {
+		t.Errorf("pool-wide palette")
+	}
+	if d1.Image().Rect.Eq(r) != true || d1.Image().Stride != r.Dx() {
+		t.Errorf("adjust rect=%s stride=%d", d1.Image().Rect, d1.Image().Stride)
+	}
+	d2 := mp.GetRefWithPalette(image.Rect(0, 0, 100, 50), palette.WebSafe)
+	if len(d2.Image().Palette) != len(palette.WebSafe) {
+		t.Errorf("caller palette")
+	}
+	d1.Release()
+	d2.Release()
+	if mp.pools[0].Len() != 1 || mp.pools[1].Len() != 1 {
+		t.Errorf("released len = %d, %d", mp.pools[0].Len(), mp.pools[1].Len())
+	}
+}
+
+func TestMultiImagePoolStrideAlignment(t *testing.T) {
+	t.Run("rgba", func(tt *testing.T) {
+		mp := NewMultiImageRGBAPool(
+			MultiImagePoolSize(10, image.Rect(0, 0, 64, 36)),
+			MultiImagePoolOption(StrideAlignment(64)),
+		)
+		for _, r := range []image.Rectangle{image.Rect(0, 0, 33, 20), image.Rect(0, 0, 100, 50)} {
+			ref := mp.GetRef(r)
+			img := ref.Image()
+			if img.Stride%64 != 0 || isAligned(img.Pix, 64) != true {
+				tt.Errorf("%s stride = %d", r, img.Stride)
+			}
+			img.Set(r.Max.X-1, r.Max.Y-1, color.White)
+			ref.Release()
+		}
+	})
+	t.Run("ycbcr", func(tt *testing.T) {
+		mp := NewMultiImageYCbCrPool(
+			image.YCbCrSubsampleRatio420,
+			MultiImagePoolSize(10, image.Rect(0, 0, 64, 36)),
+			MultiImagePoolSize(10, image.Rect(0, 0, 128, 72)),
+			MultiImagePoolOption(StrideAlignment(32)),
+		)
+		for _, r := range []image.Rectangle{image.Rect(1, 1, 40, 30), image.Rect(0, 0, 100, 70)} {
+			ref := mp.GetRef(r)
+			img := ref.Image()
+			if img.YStride%32 != 0 || img.CStride%32 != 0 {
+				tt.Errorf("%s stride Y=%d C=%d", r, img.YStride, img.CStride)
+			}
+			for _, plane := range [][]byte{img.Y, img.Cb, img.Cr} {
+				if isAligned(plane, 32) != true {
+					tt.Errorf("%s plane is not aligned", r)
+				}
+			}
+			img.Cr[img.COffset(r.Max.X-1, r.Max.Y-1)] = 0xff
+			ref.Release()
+		}
+	})
+	t.Run("nycbcra", func(tt *testing.T) {
+		mp := NewMultiImageNYCbCrAPool(
+			image.YCbCrSubsampleRatio422,
+			MultiImagePoolSize(10, image.Rect(0, 0, 64, 36)),
+			MultiImagePoolOption(StrideAlignment(64)),
+		)
+		r := image.Rect(3, 3, 50, 30)
+		img := mp.GetRef(r).Image()
+		if img.AStride != 64 || isAligned(img.A, 64) != true {
+			tt.Errorf("alpha stride = %d", img.AStride)
+		}
+		img.A[img.AOffset(r.Max.X-1, r.Max.Y-1)] = 0xff
+	})
+}
+
+func TestMultiImagePoolMaxOutstanding(t *testing.T) {
+	mp := NewMultiImageRGBAPool(
+		MultiImagePoolSize(10, image.Rect(0, 0, 16, 16)),
+		MultiImagePoolOption(MaxOutstanding(1)),
+	)
+	r1 := mp.GetRef(image.Rect(0, 0, 16, 16))
+	r2 := mp.GetRef(image.Rect(0, 0, 32, 32)) // fallback, not acquired
+	r2.Release()
+
+	done := make(chan *ImageRGBARef)
+	go func() {
+		done <- mp.GetRef(image.Rect(0, 0, 16, 16))
+	}()
+	select {
+	case <-done:
+		t.Fatalf("fallback ref must not release outstanding of r1")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	r1.Release()
+	select {
+	case r3 := <-done:
+		r3.Release()
+	case <-time.After(time.Second):
+		t.Fatalf("must be acquired after r1 released")
+	}
+}
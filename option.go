@@ -1,5 +1,9 @@
 package bp
 
+import (
+	"time"
+)
+
 type optionFunc func(*option)
 
 const (
@@ -7,13 +11,39 @@ const (
 	defaultPreloadRate      float64 = 0.25
 	defaultMaxBufSizeFactor float64 = 1.25
 	defaultAutoGrowEnable   bool    = false
+	defaultMmapSlabSize     int     = 256 * 1024
 )
 
 type option struct {
-	preload          bool
-	preloadRate      float64
-	maxBufSizeFactor float64
-	autoGrow         bool
+	preload           bool
+	preloadRate       float64
+	maxBufSizeFactor  float64
+	autoGrow          bool
+	resetFunc         interface{}
+	acceptFunc        interface{}
+	leakDetect        bool
+	leakHandler       LeakHandlerFunc
+	provenance        bool
+	provenanceHandler ProvenanceHandlerFunc
+	maxOutstanding    int
+	sharded           bool
+	shards            int
+	lifo              bool
+	idleTimeout       time.Duration
+	minIdle           int
+	budget            *Budget
+	budgetName        string
+	mmapHugePage      bool
+	mmapPopulate      bool
+	mmapLock          bool
+	mmapFreeIdle      bool
+	mmapSlabSize      int
+	filePreallocate   bool
+	mmapGuardPage     bool
+	mmapProtectIdle   bool
+	alignment         int
+	zeroPolicy        ZeroPolicy
+	strideAlignment   int
 }
 
 func newOption() *option {
@@ -22,6 +52,7 @@ func newOption() *option {
 		preloadRate:      defaultPreloadRate,
 		maxBufSizeFactor: defaultMaxBufSizeFactor,
 		autoGrow:         defaultAutoGrowEnable,
+		mmapSlabSize:     defaultMmapSlabSize,
 	}
 }
 
@@ -48,3 +79,179 @@ func AutoGrow(enable bool) optionFunc {
 		opt.autoGrow = enable
 	}
 }
+
+// ResetFunc sets the hook to reset T before it is returned to Pool[T]
+func ResetFunc[T any](fn func(T) T) optionFunc {
+	return func(opt *option) {
+		opt.resetFunc = fn
+	}
+}
+
+// AcceptFunc sets the hook to decide whether T can be returned to Pool[T]
+func AcceptFunc[T any](fn func(T) bool) optionFunc {
+	return func(opt *option) {
+		opt.acceptFunc = fn
+	}
+}
+
+// LeakDetect captures the stack of GetRef and reports Ref released by finalizer (not Release()-ed)
+func LeakDetect(enable bool) optionFunc {
+	return func(opt *option) {
+		opt.leakDetect = enable
+	}
+}
+
+// LeakHandler enables LeakDetect and calls fn instead of logging when Ref leak is detected
+func LeakHandler(fn LeakHandlerFunc) optionFunc {
+	return func(opt *option) {
+		opt.leakDetect = true
+		opt.leakHandler = fn
+	}
+}
+
+// TrackProvenance records []byte lent out by BytePool/MmapBytePool, and rejects duplicate or foreign Put.
+// it remembers pool size + MaxOutstanding lent buffers (pool size * 2 without MaxOutstanding), the oldest is forgotten beyond it.
+func TrackProvenance(enable bool) optionFunc {
+	return func(opt *option) {
+		opt.provenance = enable
+	}
+}
+
+// ProvenanceHandler enables TrackProvenance and calls fn when Put is rejected
+func ProvenanceHandler(fn ProvenanceHandlerFunc) optionFunc {
+	return func(opt *option) {
+		opt.provenance = true
+		opt.provenanceHandler = fn
+	}
+}
+
+// MaxOutstanding limits the number of objects in use, Get waits until Put when limit is reached
+func MaxOutstanding(n int) optionFunc {
+	return func(opt *option) {
+		opt.maxOutstanding = n
+	}
+}
+
+// Sharded splits pool into shards to reduce contention of single channel,
+// shards <= 0 uses GOMAXPROCS.
+func Sharded(shards int) optionFunc {
+	return func(opt *option) {
+		opt.sharded = true
+		opt.shards = shards
+	}
+}
+
+// LIFO uses lock-free stack instead of channel (FIFO), Get returns the most recently Put object
+// which is likely to be hot in CPU cache (and not paged out for MmapBytePool).
+func LIFO(enable bool) optionFunc {
+	return func(opt *option) {
+		opt.lifo = enable
+	}
+}
+
+// IdleTimeout calls Trim periodically, pooled objects unused for timeout are released
+// (and Munmap-ed in MmapBytePool). FileMmapBytePool and SharedMmapBytePool ignore it, their slots are fixed.
+func IdleTimeout(timeout time.Duration) optionFunc {
+	return func(opt *option) {
+		opt.idleTimeout = timeout
+	}
+}
+
+// MinIdle is the floor of Trim, pool keeps at least n objects.
+func MinIdle(n int) optionFunc {
+	return func(opt *option) {
+		opt.minIdle = n
+	}
+}
+
+// AttachBudget accounts bytes retained by pool to budget as name, Put is discarded when budget is exceeded.
+// pools of multi pool are accounted to the same name, Pool[T], TickerPool and TimerPool are not accounted.
+func AttachBudget(budget *Budget, name string) optionFunc {
+	return func(opt *option) {
+		opt.budget = budget
+		opt.budgetName = name
+	}
+}
+
+// MmapHugePage requests transparent huge page by madvise(MADV_HUGEPAGE) for MmapBytePool (linux only),
+// slab is aligned and rounded up to the huge page size.
+func MmapHugePage(enable bool) optionFunc {
+	return func(opt *option) {
+		opt.mmapHugePage = enable
+	}
+}
+
+// MmapPopulate prefaults buffers of MmapBytePool by MAP_POPULATE (linux only)
+func MmapPopulate(enable bool) optionFunc {
+	return func(opt *option) {
+		opt.mmapPopulate = enable
+	}
+}
+
+// MmapLock locks buffers of MmapBytePool in memory by mlock
+func MmapLock(enable bool) optionFunc {
+	return func(opt *option) {
+		opt.mmapLock = enable
+	}
+}
+
+// MmapFreeIdle tells kernel that pages of buffers returned to MmapBytePool are reclaimable by madvise(MADV_FREE),
+// fallback to MADV_DONTNEED if MADV_FREE is not available. contents of the buffer are not kept after Put.
+func MmapFreeIdle(enable bool) optionFunc {
+	return func(opt *option) {
+		opt.mmapFreeIdle = enable
+	}
+}
+
+// MmapSlabSize sets the size of a mapping that MmapBytePool splits into buffers,
+// slab is unmapped when all buffers in the slab are discarded.
+func MmapSlabSize(size int) optionFunc {
+	return func(opt *option) {
+		opt.mmapSlabSize = size
+	}
+}
+
+// FilePreallocate allocates blocks of the backing file of FileMmapBytePool, otherwise the file is sparse
+func FilePreallocate(enable bool) optionFunc {
+	return func(opt *option) {
+		opt.filePreallocate = enable
+	}
+}
+
+// MmapGuardPage places PROT_NONE page after each buffer of MmapBytePool and right-aligns the buffer to it,
+// overflow of the buffer crashes with SIGSEGV. it is for debugging, each buffer uses extra pages.
+func MmapGuardPage(enable bool) optionFunc {
+	return func(opt *option) {
+		opt.mmapGuardPage = enable
+	}
+}
+
+// MmapProtectIdle protects buffers of MmapBytePool by PROT_NONE while they are in the pool and re-enables in Get,
+// access after Put (or Release) crashes with SIGSEGV. it is for debugging, each buffer uses whole pages.
+func MmapProtectIdle(enable bool) optionFunc {
+	return func(opt *option) {
+		opt.mmapProtectIdle = enable
+	}
+}
+
+// Alignment aligns start address and length of buffers of BytePool and MmapBytePool to size (power of 2, e.g. 512 or 4096),
+// bufSize is rounded up to multiple of size. MmapBytePool supports size up to page size, larger size panics.
+func Alignment(size int) optionFunc {
+	return func(opt *option) {
+		opt.alignment = size
+	}
+}
+
+// Zeroing sets when buffers of BytePool, BufferPool and MmapBytePool are wiped, default is ZeroNone.
+func Zeroing(policy ZeroPolicy) optionFunc {
+	return func(opt *option) {
+		opt.zeroPolicy = policy
+	}
+}
+
+// StrideAlignment pads stride of rows and aligns start of each plane of image pools to size (e.g. 32 or 64 for SIMD)
+func StrideAlignment(size int) optionFunc {
+	return func(opt *option) {
+		opt.strideAlignment = size
+	}
+}
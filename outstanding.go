@@ -0,0 +1,125 @@
+package bp
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// outstanding limits objects in use to MaxOutstanding,
+// slot is released only by Put of the object that was lent by GetContext.
+type outstanding[T any] struct {
+	sem   chan struct{}
+	mutex *sync.Mutex
+	lent  map[uintptr]int // slots held by identity of object
+	key   func(T) uintptr // nil if T has no identity
+}
+
+// acquire waits until outstanding falls below the limit
+func (o *outstanding[T]) acquire(ctx context.Context) error {
+	select {
+	case o.sem <- struct{}{}:
+		return nil
+	default:
+		// limit reached, wait for Put
+	}
+
+	select {
+	case o.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// lend records data holds acquired slot
+func (o *outstanding[T]) lend(data T) {
+	if o.key == nil {
+		return
+	}
+	key := o.key(data)
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.lent[key] += 1
+}
+
+// release frees slot held by data, nothing if data was not lent
+func (o *outstanding[T]) release(data T) {
+	if o.key != nil {
+		if o.returned(o.key(data)) != true {
+			// foreign object or already released
+			return
+		}
+	}
+
+	select {
+	case <-o.sem:
+	default:
+		// not acquired
+	}
+}
+
+// releaseLent frees slot held by data only if data was lent, used by Ref that knows the lent object
+func (o *outstanding[T]) releaseLent(data T) {
+	if o.key == nil {
+		// released by Put
+		return
+	}
+	o.release(data)
+}
+
+func (o *outstanding[T]) returned(key uintptr) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	n, ok := o.lent[key]
+	if ok != true {
+		return false
+	}
+	if n <= 1 {
+		delete(o.lent, key)
+	} else {
+		o.lent[key] = n - 1
+	}
+	return true
+}
+
+func newOutstanding[T any](opt *option) *outstanding[T] {
+	if opt.maxOutstanding < 1 {
+		return nil
+	}
+	return &outstanding[T]{
+		sem:   make(chan struct{}, opt.maxOutstanding),
+		mutex: new(sync.Mutex),
+		lent:  make(map[uintptr]int),
+		key:   identityKey[T](),
+	}
+}
+
+// identityKey returns address of pointer or backing array of slice, nil if T has no identity (e.g. int, struct)
+func identityKey[T any]() func(T) uintptr {
+	switch reflect.TypeOf((*T)(nil)).Elem().Kind() {
+	case reflect.Ptr, reflect.UnsafePointer, reflect.Map, reflect.Chan, reflect.Slice:
+		return func(data T) uintptr {
+			// first word is the pointer
+			return uintptr(*(*unsafe.Pointer)(unsafe.Pointer(&data)))
+		}
+	}
+	return nil
+}
+
+// lentReleaser frees MaxOutstanding slot by the object lent to Ref,
+// the field of Ref may be replaced (e.g. append to ByteRef.B) before Put.
+type lentReleaser[T any] interface {
+	releaseLent(T)
+}
+
+// releaseLent frees slot of lent before Put, Put of the same object does not free it again
+func releaseLent[T any](pool interface{}, lent T) {
+	if r, ok := pool.(lentReleaser[T]); ok {
+		r.releaseLent(lent)
+	}
+}
@@ -0,0 +1,381 @@
+package bp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	mismatchResetFuncType  string = "ResetFunc type does not match pool type"
+	mismatchAcceptFuncType string = "AcceptFunc type does not match pool type"
+)
+
+var (
+	ErrPoolClosed = errors.New("pool already closed")
+)
+
+// Pool is a fixed-size pool of any T.
+// every pool in this package is built on Pool.
+type Pool[T any] struct {
+	pool       storage[T]
+	newFunc    func() T
+	resetFunc  func(T) T
+	acceptFunc func(T) bool
+	stats      *poolStats
+	leak       *leakDetector
+	limit      *outstanding[T] // MaxOutstanding, nil if unbounded
+	dispose    func(T)         // releases trimmed object, nil if GC releases it
+	lowWater   int64           // minimum Len after Get since previous Trim, -1 if no Get
+	trimming   int32           // 1 after tracking is armed by IdleTimeout or first Trim, Get tracks lowWater
+	minIdle    int
+	trimMutex  *sync.Mutex
+	trimTimer  *time.Timer
+	budget     *budgetAccount // nil if Budget is not attached
+	closed     int32
+}
+
+func (p *Pool[T]) GetRef() *ObjectRef[T] {
+	data := p.Get()
+
+	ref := newObjectRef[T](data, p)
+	ref.setFinalizer()
+	return ref
+}
+
+func (p *Pool[T]) GetRefContext(ctx context.Context) (*ObjectRef[T], error) {
+	data, err := p.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := newObjectRef[T](data, p)
+	ref.setFinalizer()
+	return ref, nil
+}
+
+func (p *Pool[T]) preload(rate float64) {
+	if 0 < p.pool.cap() {
+		preloadSize := int(float64(p.pool.cap()) * rate)
+		for i := 0; i < preloadSize; i += 1 {
+			p.Put(p.newFunc())
+		}
+	}
+}
+
+// get returns pooled value, false if pool is empty
+func (p *Pool[T]) get() (T, bool) {
+	if data, ok := p.pool.pop(); ok {
+		// reuse exists pool
+		p.unaccount()
+		p.stats.hit()
+		p.markLowWater()
+		return data, true
+	}
+	p.stats.miss()
+	var empty T
+	return empty, false
+}
+
+// put stores data without accept/reset hooks
+func (p *Pool[T]) put(data T) bool {
+	if p.isClosed() {
+		// discard, pool no longer retains objects
+		p.stats.discardClosed()
+		return false
+	}
+
+	if p.budget != nil {
+		if p.budget.reserve() != true {
+			// exceeds budget, discard it
+			p.stats.discardBudget()
+			return false
+		}
+	}
+
+	if p.pool.push(data) {
+		// free capacity
+		p.stats.accepted()
+		if p.isClosed() {
+			// Close raced with push, release it too
+			p.drain()
+		}
+		return true
+	}
+	p.unaccount()
+	// full capacity, discard it
+	p.stats.discardFull()
+	return false
+}
+
+// unaccount releases budget of an object which is no longer retained by pool
+func (p *Pool[T]) unaccount() {
+	if p.budget != nil {
+		p.budget.release()
+	}
+}
+
+// acquire waits until outstanding falls below the limit of MaxOutstanding
+func (p *Pool[T]) acquire(ctx context.Context) error {
+	if p.limit == nil {
+		return nil
+	}
+
+	if err := p.limit.acquire(ctx); err != nil {
+		p.stats.canceled()
+		return err
+	}
+	return nil
+}
+
+// release decrements outstanding if data was lent by GetContext
+func (p *Pool[T]) release(data T) {
+	if p.limit == nil {
+		return
+	}
+	p.limit.release(data)
+}
+
+func (p *Pool[T]) releaseLent(data T) {
+	if p.limit == nil {
+		return
+	}
+	p.limit.releaseLent(data)
+}
+
+func (p *Pool[T]) Get() T {
+	data, _ := p.GetContext(context.Background())
+	return data
+}
+
+// GetContext is same as Get, it waits while MaxOutstanding objects are in use until ctx is done
+func (p *Pool[T]) GetContext(ctx context.Context) (T, error) {
+	if err := p.acquire(ctx); err != nil {
+		var empty T
+		return empty, err
+	}
+
+	data, ok := p.get()
+	if ok != true {
+		// create new one
+		data = p.newFunc()
+	}
+	if p.limit != nil {
+		p.limit.lend(data)
+	}
+	return data, nil
+}
+
+func (p *Pool[T]) Put(data T) bool {
+	// release outstanding after stored, waiting GetContext can reuse it
+	defer p.release(data)
+
+	return p.store(data)
+}
+
+// store returns data to pool with accept/reset hooks, without release outstanding
+func (p *Pool[T]) store(data T) bool {
+	if p.acceptFunc != nil {
+		if p.acceptFunc(data) != true {
+			// discard
+			p.stats.discardRejected()
+			return false
+		}
+	}
+
+	if p.resetFunc != nil {
+		data = p.resetFunc(data)
+	}
+	return p.put(data)
+}
+
+// markLowWater records Len after Get, objects above it were not used until next Trim.
+// it does nothing until tracking is armed, Get without trimming does not pay for Len.
+func (p *Pool[T]) markLowWater() {
+	if atomic.LoadInt32(&p.trimming) != 1 {
+		return
+	}
+	n := int64(p.pool.len())
+	for {
+		lw := atomic.LoadInt64(&p.lowWater)
+		if 0 <= lw && lw <= n {
+			return
+		}
+		if atomic.CompareAndSwapInt64(&p.lowWater, lw, n) {
+			return
+		}
+	}
+}
+
+// armTrim starts tracking of unused objects, all pooled objects are unused until Get
+func (p *Pool[T]) armTrim() {
+	atomic.StoreInt64(&p.lowWater, -1)
+	atomic.StoreInt32(&p.trimming, 1)
+}
+
+// Trim releases pooled objects that were not used since previous Trim, keeps at least MinIdle objects.
+// it returns the number of released objects.
+// unused objects are tracked from IdleTimeout or from the first Trim, so the first manual Trim
+// without IdleTimeout only arms tracking and releases nothing.
+func (p *Pool[T]) Trim() int {
+	p.trimMutex.Lock()
+	defer p.trimMutex.Unlock()
+
+	idle := 0
+	if atomic.LoadInt32(&p.trimming) == 1 {
+		idle = p.pool.len()
+		if lw := int(atomic.LoadInt64(&p.lowWater)); 0 <= lw && lw < idle {
+			idle = lw
+		}
+	}
+
+	released := 0
+	for released < idle-p.minIdle {
+		data, ok := p.pool.pop()
+		if ok != true {
+			break
+		}
+		p.unaccount()
+		if p.dispose != nil {
+			p.dispose(data)
+		}
+		released += 1
+	}
+	p.armTrim()
+	p.stats.trimmed(released)
+	return released
+}
+
+// startTrim calls Trim every interval, so objects unused for interval are released
+func (p *Pool[T]) startTrim(interval time.Duration) {
+	p.trimMutex.Lock()
+	defer p.trimMutex.Unlock()
+
+	p.trimTimer = time.AfterFunc(interval, func() {
+		p.Trim()
+
+		p.trimMutex.Lock()
+		defer p.trimMutex.Unlock()
+		if p.isClosed() {
+			return
+		}
+		p.trimTimer.Reset(interval)
+	})
+}
+
+func (p *Pool[T]) stopTrim() {
+	p.trimMutex.Lock()
+	defer p.trimMutex.Unlock()
+
+	if p.trimTimer != nil {
+		p.trimTimer.Stop()
+	}
+}
+
+// drain releases all pooled objects
+func (p *Pool[T]) drain() int {
+	released := 0
+	for {
+		data, ok := p.pool.pop()
+		if ok != true {
+			return released
+		}
+		p.unaccount()
+		if p.dispose != nil {
+			p.dispose(data)
+		}
+		released += 1
+	}
+}
+
+func (p *Pool[T]) isClosed() bool {
+	return atomic.LoadInt32(&p.closed) == 1
+}
+
+// Close stops IdleTimeout and releases all pooled objects.
+// Put after Close is discarded, Get still returns newly created object.
+func (p *Pool[T]) Close() error {
+	if atomic.CompareAndSwapInt32(&p.closed, 0, 1) != true {
+		return ErrPoolClosed
+	}
+	p.stopTrim()
+	p.drain()
+	return nil
+}
+
+// Drain waits until all Refs obtained by GetRef are released or ctx is done
+func (p *Pool[T]) Drain(ctx context.Context) error {
+	return p.stats.waitDrained(ctx)
+}
+
+func (p *Pool[T]) Len() int {
+	return p.pool.len()
+}
+
+func (p *Pool[T]) Cap() int {
+	return p.pool.cap()
+}
+
+func (p *Pool[T]) Stats() Stats {
+	return p.stats.snapshot(p.Len(), p.Cap())
+}
+
+func (p *Pool[T]) poolStats() *poolStats {
+	return p.stats
+}
+
+func (p *Pool[T]) leakDetector() *leakDetector {
+	return p.leak
+}
+
+func newPool[T any](poolSize int, newFunc func() T, opt *option) *Pool[T] {
+	p := &Pool[T]{
+		pool:      newStorage[T](poolSize, opt),
+		newFunc:   newFunc,
+		stats:     newPoolStats(),
+		leak:      newLeakDetector(opt),
+		limit:     newOutstanding[T](opt),
+		minIdle:   opt.minIdle,
+		trimMutex: new(sync.Mutex),
+	}
+	if 0 < opt.idleTimeout {
+		p.armTrim()
+		p.startTrim(opt.idleTimeout)
+	}
+	return p
+}
+
+// NewPool returns fixed-size pool of T, newFunc creates T when pool is empty.
+// ResetFunc and AcceptFunc can be used to hook Put.
+func NewPool[T any](poolSize int, newFunc func() T, funcs ...optionFunc) *Pool[T] {
+	opt := newOption()
+	for _, fn := range funcs {
+		fn(opt)
+	}
+
+	p := newPool[T](poolSize, newFunc, opt)
+
+	if opt.resetFunc != nil {
+		fn, ok := opt.resetFunc.(func(T) T)
+		if ok != true {
+			panic(mismatchResetFuncType)
+		}
+		p.resetFunc = fn
+	}
+
+	if opt.acceptFunc != nil {
+		fn, ok := opt.acceptFunc.(func(T) bool)
+		if ok != true {
+			panic(mismatchAcceptFuncType)
+		}
+		p.acceptFunc = fn
+	}
+
+	if opt.preload {
+		p.preload(opt.preloadRate)
+	}
+
+	return p
+}
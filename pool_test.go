@@ -0,0 +1,455 @@
+package bp
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type testPoolItem struct {
+	id   int
+	data []int
+}
+
+func TestPoolGetPut(t *testing.T) {
+	t.Run("new", func(tt *testing.T) {
+		created := 0
+		p := NewPool(10, func() *testPoolItem {
+			created += 1
+			return &testPoolItem{id: created}
+		})
+		d1 := p.Get()
+		d2 := p.Get()
+		if d1.id != 1 || d2.id != 2 {
+			tt.Errorf("create new item: %d %d", d1.id, d2.id)
+		}
+		if p.Len() != 0 {
+			tt.Errorf("empty pool")
+		}
+		if p.Put(d1) != true {
+			tt.Errorf("free capacity")
+		}
+		d3 := p.Get()
+		if d3.id != 1 {
+			tt.Errorf("reuse pooled item: %d", d3.id)
+		}
+		if created != 2 {
+			tt.Errorf("created = %d", created)
+		}
+	})
+	t.Run("fullcap", func(tt *testing.T) {
+		p := NewPool(2, func() *testPoolItem {
+			return new(testPoolItem)
+		})
+		if p.Put(new(testPoolItem)) != true {
+			tt.Errorf("free capacity")
+		}
+		if p.Put(new(testPoolItem)) != true {
+			tt.Errorf("free capacity")
+		}
+		if p.Put(new(testPoolItem)) {
+			tt.Errorf("fulled capacity %d", p.Cap())
+		}
+		if p.Len() != 2 {
+			tt.Errorf("fixed size pool: %d", p.Len())
+		}
+		if p.Cap() != 2 {
+			tt.Errorf("max capacity = 2")
+		}
+	})
+	t.Run("reset", func(tt *testing.T) {
+		p := NewPool(10, func() *testPoolItem {
+			return new(testPoolItem)
+		}, ResetFunc(func(v *testPoolItem) *testPoolItem {
+			v.data = v.data[:0]
+			return v
+		}))
+		d1 := p.Get()
+		d1.data = append(d1.data, 1, 2, 3)
+		p.Put(d1)
+
+		d2 := p.Get()
+		if len(d2.data) != 0 {
+			tt.Errorf("reset on put: %v", d2.data)
+		}
+		if cap(d2.data) < 3 {
+			tt.Errorf("keep capacity: %d", cap(d2.data))
+		}
+	})
+	t.Run("accept", func(tt *testing.T) {
+		p := NewPool(10, func() *testPoolItem {
+			return new(testPoolItem)
+		}, AcceptFunc(func(v *testPoolItem) bool {
+			return cap(v.data) <= 4
+		}))
+		if p.Put(&testPoolItem{data: make([]int, 4)}) != true {
+			tt.Errorf("accept")
+		}
+		if p.Put(&testPoolItem{data: make([]int, 5)}) {
+			tt.Errorf("discard too big")
+		}
+		if p.Len() != 1 {
+			tt.Errorf("discard: %d", p.Len())
+		}
+	})
+	t.Run("mismatch", func(tt *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				tt.Errorf("mismatch type must panic")
+			}
+		}()
+		NewPool(10, func() *testPoolItem {
+			return new(testPoolItem)
+		}, ResetFunc(func(v []byte) []byte {
+			return v
+		}))
+	})
+}
+
+func TestPoolPreload(t *testing.T) {
+	p := NewPool(12, func() []int {
+		return make([]int, 8)
+	}, Preload(true))
+	l := int(float64(12) * defaultPreloadRate)
+	if p.Len() != l {
+		t.Errorf("preloaded = %d", p.Len())
+	}
+}
+
+func TestPoolRef(t *testing.T) {
+	p := NewPool(10, func() *testPoolItem {
+		return &testPoolItem{id: 123}
+	})
+	r := p.GetRef()
+	if r.Value().id != 123 {
+		t.Errorf("same value")
+	}
+	if p.Len() != 0 {
+		t.Errorf("acquire pool")
+	}
+	r.Release()
+	if p.Len() != 1 {
+		t.Errorf("release pool")
+	}
+	r.Release()
+	if p.Len() != 1 {
+		t.Errorf("double release")
+	}
+}
+
+func TestPoolMaxOutstanding(t *testing.T) {
+	t.Run("wait", func(tt *testing.T) {
+		p := NewPool(10, func() *testPoolItem {
+			return new(testPoolItem)
+		}, MaxOutstanding(2))
+		d1 := p.Get()
+		d2 := p.Get()
+
+		done := make(chan *testPoolItem)
+		go func() {
+			d, err := p.GetContext(context.Background())
+			if err != nil {
+				tt.Errorf("no error: %+v", err)
+			}
+			done <- d
+		}()
+
+		select {
+		case <-done:
+			tt.Fatalf("must wait for Put")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		p.Put(d1)
+		select {
+		case d3 := <-done:
+			if d3 != d1 {
+				tt.Errorf("reuse released item")
+			}
+		case <-time.After(time.Second):
+			tt.Fatalf("must be acquired after Put")
+		}
+		p.Put(d2)
+	})
+	t.Run("timeout", func(tt *testing.T) {
+		p := NewPool(10, func() *testPoolItem {
+			return new(testPoolItem)
+		}, MaxOutstanding(1))
+		r1, err := p.GetRefContext(context.Background())
+		if err != nil {
+			tt.Fatalf("no error: %+v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		if _, err := p.GetRefContext(ctx); err != context.DeadlineExceeded {
+			tt.Errorf("deadline exceeded: %+v", err)
+		}
+		if s := p.Stats(); s.GetCanceled != 1 {
+			tt.Errorf("canceled = %d", s.GetCanceled)
+		}
+
+		r1.Release()
+		r2, err := p.GetRefContext(context.Background())
+		if err != nil {
+			tt.Errorf("no error: %+v", err)
+		}
+		r2.Release()
+	})
+	t.Run("unbounded", func(tt *testing.T) {
+		p := NewPool(1, func() *testPoolItem {
+			return new(testPoolItem)
+		})
+		for i := 0; i < 10; i += 1 {
+			if _, err := p.GetContext(context.Background()); err != nil {
+				tt.Errorf("no error: %+v", err)
+			}
+		}
+		// never below zero
+		p.Put(new(testPoolItem))
+		p.Put(new(testPoolItem))
+	})
+	t.Run("notlent", func(tt *testing.T) {
+		p := NewPool(10, func() *testPoolItem {
+			return new(testPoolItem)
+		}, MaxOutstanding(1))
+		r1, err := p.GetRefContext(context.Background())
+		if err != nil {
+			tt.Fatalf("no error: %+v", err)
+		}
+
+		// foreign and duplicate Put dont release slot of r1
+		d := new(testPoolItem)
+		p.Put(d)
+		p.Put(d)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := p.GetContext(ctx); err != context.DeadlineExceeded {
+			tt.Errorf("r1 is still outstanding: %+v", err)
+		}
+		r1.Release()
+	})
+}
+
+func TestPoolTrim(t *testing.T) {
+	t.Run("idle", func(tt *testing.T) {
+		p := NewPool(10, func() *testPoolItem {
+			return new(testPoolItem)
+		})
+		for i := 0; i < 10; i += 1 {
+			p.Put(new(testPoolItem))
+		}
+		// not used since previous Trim
+		p.Trim()
+		if n := p.Trim(); n != 10 {
+			tt.Errorf("released idle = %d", n)
+		}
+		if p.Len() != 0 {
+			tt.Errorf("len = %d", p.Len())
+		}
+		if s := p.Stats(); s.Trimmed != 10 {
+			tt.Errorf("trimmed = %d", s.Trimmed)
+		}
+	})
+	t.Run("used", func(tt *testing.T) {
+		p := NewPool(10, func() *testPoolItem {
+			return new(testPoolItem)
+		})
+		for i := 0; i < 10; i += 1 {
+			p.Put(new(testPoolItem))
+		}
+		p.Trim()
+
+		// 3 objects are in use
+		items := []*testPoolItem{p.Get(), p.Get(), p.Get()}
+		for _, item := range items {
+			p.Put(item)
+		}
+		if n := p.Trim(); n != 7 {
+			tt.Errorf("released unused = %d", n)
+		}
+		if p.Len() != 3 {
+			tt.Errorf("len = %d", p.Len())
+		}
+	})
+	t.Run("untracked", func(tt *testing.T) {
+		p := NewPool(10, func() *testPoolItem {
+			return new(testPoolItem)
+		}, Sharded(4))
+		for i := 0; i < 10; i += 1 {
+			p.Put(new(testPoolItem))
+		}
+		// Get does not track low-water mark until first Trim
+		p.Put(p.Get())
+		if p.trimming != 0 || p.lowWater != 0 {
+			tt.Errorf("tracked without Trim: lowWater = %d", p.lowWater)
+		}
+
+		p.Trim()
+		p.Put(p.Get())
+		if p.lowWater != 9 {
+			tt.Errorf("tracked after Trim: lowWater = %d", p.lowWater)
+		}
+	})
+	t.Run("armed", func(tt *testing.T) {
+		p := NewPool(10, func() *testPoolItem {
+			return new(testPoolItem)
+		}, IdleTimeout(time.Hour))
+		defer p.Close()
+
+		for i := 0; i < 10; i += 1 {
+			p.Put(new(testPoolItem))
+		}
+		// IdleTimeout tracks from construction, first Trim releases unused objects
+		if n := p.Trim(); n != 10 {
+			tt.Errorf("released at first Trim = %d", n)
+		}
+	})
+	t.Run("minidle", func(tt *testing.T) {
+		p := NewPool(10, func() *testPoolItem {
+			return new(testPoolItem)
+		}, MinIdle(4))
+		for i := 0; i < 10; i += 1 {
+			p.Put(new(testPoolItem))
+		}
+		p.Trim()
+		if n := p.Trim(); n != 6 {
+			tt.Errorf("released above floor = %d", n)
+		}
+		if n := p.Trim(); n != 0 {
+			tt.Errorf("keep floor = %d", n)
+		}
+		if p.Len() != 4 {
+			tt.Errorf("len = %d", p.Len())
+		}
+	})
+	t.Run("dispose", func(tt *testing.T) {
+		p := NewPool(10, func() *testPoolItem {
+			return new(testPoolItem)
+		})
+		disposed := 0
+		p.dispose = func(*testPoolItem) {
+			disposed += 1
+		}
+		for i := 0; i < 5; i += 1 {
+			p.Put(new(testPoolItem))
+		}
+		p.Trim()
+		p.Trim()
+		if disposed != 5 {
+			tt.Errorf("disposed = %d", disposed)
+		}
+	})
+	t.Run("timeout", func(tt *testing.T) {
+		p := NewPool(10, func() *testPoolItem {
+			return new(testPoolItem)
+		}, IdleTimeout(10*time.Millisecond), MinIdle(1))
+		for i := 0; i < 10; i += 1 {
+			p.Put(new(testPoolItem))
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+		for 1 < p.Len() && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if p.Len() != 1 {
+			tt.Errorf("trimmed by idle timeout: len = %d", p.Len())
+		}
+	})
+}
+
+func TestPoolClose(t *testing.T) {
+	t.Run("close", func(tt *testing.T) {
+		p := NewPool(10, func() *testPoolItem {
+			return new(testPoolItem)
+		})
+		for i := 0; i < 10; i += 1 {
+			p.Put(new(testPoolItem))
+		}
+		if err := p.Close(); err != nil {
+			tt.Errorf("close: %+v", err)
+		}
+		if p.Len() != 0 {
+			tt.Errorf("len = %d", p.Len())
+		}
+		if p.Put(new(testPoolItem)) {
+			tt.Errorf("put after close")
+		}
+		if s := p.Stats(); s.PutDiscardClosed != 1 {
+			tt.Errorf("discard closed = %d", s.PutDiscardClosed)
+		}
+		if p.Get() == nil {
+			tt.Errorf("get after close creates new one")
+		}
+		if err := p.Close(); err != ErrPoolClosed {
+			tt.Errorf("close twice: %+v", err)
+		}
+	})
+	t.Run("idletimeout", func(tt *testing.T) {
+		p := NewPool(10, func() *testPoolItem {
+			return new(testPoolItem)
+		}, IdleTimeout(10*time.Millisecond))
+		p.Close()
+		p.trimMutex.Lock()
+		defer p.trimMutex.Unlock()
+
+		if p.trimTimer.Stop() {
+			tt.Errorf("trim timer is not stopped")
+		}
+	})
+}
+
+func TestPoolDrain(t *testing.T) {
+	t.Run("empty", func(tt *testing.T) {
+		p := NewPool(10, func() *testPoolItem {
+			return new(testPoolItem)
+		})
+		if err := p.Drain(context.Background()); err != nil {
+			tt.Errorf("no outstanding: %+v", err)
+		}
+	})
+	t.Run("release", func(tt *testing.T) {
+		p := NewPool(10, func() *testPoolItem {
+			return new(testPoolItem)
+		})
+		r1 := p.GetRef()
+		r2 := p.GetRef()
+
+		done := make(chan error)
+		go func() {
+			done <- p.Drain(context.Background())
+		}()
+
+		r1.Release()
+		select {
+		case <-done:
+			tt.Errorf("drained with outstanding ref")
+		case <-time.After(10 * time.Millisecond):
+			// wait
+		}
+
+		r2.Release()
+		if err := <-done; err != nil {
+			tt.Errorf("drain: %+v", err)
+		}
+	})
+	t.Run("canceled", func(tt *testing.T) {
+		p := NewPool(10, func() *testPoolItem {
+			return new(testPoolItem)
+		})
+		r := p.GetRef()
+		defer r.Release()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		if err := p.Drain(ctx); err != context.DeadlineExceeded {
+			tt.Errorf("drain: %+v", err)
+		}
+		if n := len(p.stats.drainWaiters); n != 0 {
+			tt.Errorf("waiter is not removed: %d", n)
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package bp
+
+import (
+	"container/list"
+	"errors"
+	"log"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+const (
+	// skip runtime.Callers, captureProvenanceStack, provenance.report and provenance.put
+	provenanceStackSkip int = 4
+)
+
+var (
+	ErrPutDuplicate = errors.New("put buffer already returned to pool")
+	ErrPutForeign   = errors.New("put buffer not obtained from pool")
+)
+
+// ProvenanceInfo reports a Put rejected by provenance tracking
+type ProvenanceInfo struct {
+	Err   error  // ErrPutDuplicate or ErrPutForeign
+	Stack string // stack trace of Put
+}
+
+type ProvenanceHandlerFunc func(ProvenanceInfo)
+
+type provenanceState uint8
+
+const (
+	provenanceLent provenanceState = iota + 1
+	provenancePooled
+)
+
+// provenance tracks backing arrays of []byte that are lent out by pool,
+// lent entries are bounded by limit and the oldest one is forgotten (e.g. dropped buffer that is GC'd)
+type provenance struct {
+	mutex   *sync.Mutex
+	states  map[uintptr]provenanceState
+	lent    map[uintptr]*list.Element
+	order   *list.List // keys of lent entries, oldest first
+	limit   int
+	handler ProvenanceHandlerFunc
+}
+
+func (p *provenance) lend(data []byte) {
+	key, ok := provenanceKey(data)
+	if ok != true {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.states[key] = provenanceLent
+	if e, ok := p.lent[key]; ok {
+		p.order.MoveToBack(e)
+	} else {
+		p.lent[key] = p.order.PushBack(key)
+	}
+
+	for p.limit < p.order.Len() {
+		oldest := p.order.Remove(p.order.Front()).(uintptr)
+		delete(p.lent, oldest)
+		delete(p.states, oldest)
+	}
+}
+
+// unlent removes key from lent entries, mutex must be held
+func (p *provenance) unlent(key uintptr) {
+	if e, ok := p.lent[key]; ok {
+		p.order.Remove(e)
+		delete(p.lent, key)
+	}
+}
+
+func (p *provenance) returned(data []byte) error {
+	key, ok := provenanceKey(data)
+	if ok != true {
+		return ErrPutForeign
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	switch p.states[key] {
+	case provenanceLent:
+		p.states[key] = provenancePooled
+		p.unlent(key)
+		return nil
+	case provenancePooled:
+		return ErrPutDuplicate
+	}
+	return ErrPutForeign
+}
+
+func (p *provenance) forget(data []byte) {
+	key, ok := provenanceKey(data)
+	if ok != true {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	delete(p.states, key)
+	p.unlent(key)
+}
+
+// put returns true if data can be returned to pool, otherwise reports it
+func (p *provenance) put(data []byte, stats *poolStats) bool {
+	err := p.returned(data)
+	if err == nil {
+		return true
+	}
+
+	if err == ErrPutDuplicate {
+		stats.discardDuplicate()
+	} else {
+		stats.discardForeign()
+	}
+	p.report(err)
+	return false
+}
+
+// report calls handler with stack of Put, or logs only err if handler is not set
+func (p *provenance) report(err error) {
+	if p.handler == nil {
+		log.Printf("warn: bp: %s", err.Error())
+		return
+	}
+	p.handler(ProvenanceInfo{
+		Err:   err,
+		Stack: formatRefStack(captureProvenanceStack()),
+	})
+}
+
+// newProvenance tracks poolSize + MaxOutstanding lent buffers (poolSize * 2 if MaxOutstanding is not set)
+func newProvenance(poolSize int, opt *option) *provenance {
+	if opt.provenance != true {
+		return nil
+	}
+	limit := poolSize + opt.maxOutstanding
+	if opt.maxOutstanding < 1 {
+		limit = poolSize * 2
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	return &provenance{
+		mutex:   new(sync.Mutex),
+		states:  make(map[uintptr]provenanceState),
+		lent:    make(map[uintptr]*list.Element),
+		order:   list.New(),
+		limit:   limit,
+		handler: opt.provenanceHandler,
+	}
+}
+
+// provenanceKey returns address of backing array
+func provenanceKey(data []byte) (uintptr, bool) {
+	if cap(data) < 1 {
+		return 0, false
+	}
+	return uintptr(unsafe.Pointer(&data[:cap(data)][0])), true
+}
+
+func captureProvenanceStack() []uintptr {
+	pc := make([]uintptr, leakStackDepth)
+	n := runtime.Callers(provenanceStackSkip, pc)
+	return pc[:n]
+}
@@ -0,0 +1,172 @@
+package bp
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestProvenanceBytePool(t *testing.T) {
+	t.Run("getput", func(tt *testing.T) {
+		p := NewBytePool(10, 8, TrackProvenance(true))
+		d1 := p.Get()
+		d2 := p.Get()
+		if p.Put(d1) != true {
+			tt.Errorf("lent buffer")
+		}
+		if p.Put(d2[:0]) != true {
+			tt.Errorf("same backing array")
+		}
+		d3 := p.Get()
+		if p.Put(d3) != true {
+			tt.Errorf("reuse pooled buffer")
+		}
+	})
+	t.Run("duplicate", func(tt *testing.T) {
+		infos := make([]ProvenanceInfo, 0)
+		p := NewBytePool(10, 8, ProvenanceHandler(func(info ProvenanceInfo) {
+			infos = append(infos, info)
+		}))
+		d1 := p.Get()
+		p.Put(d1)
+		if p.Put(d1) {
+			tt.Errorf("reject duplicate put")
+		}
+		if p.Len() != 1 {
+			tt.Errorf("pooled once: %d", p.Len())
+		}
+		if len(infos) != 1 {
+			tt.Fatalf("reported: %d", len(infos))
+		}
+		if infos[0].Err != ErrPutDuplicate {
+			tt.Errorf("duplicate: %v", infos[0].Err)
+		}
+		if strings.Contains(infos[0].Stack, "provenance_test.go") != true {
+			tt.Errorf("stack of Put: %s", infos[0].Stack)
+		}
+		if s := p.Stats(); s.PutDiscardDuplicate != 1 {
+			tt.Errorf("duplicate = %d", s.PutDiscardDuplicate)
+		}
+	})
+	t.Run("foreign", func(tt *testing.T) {
+		infos := make([]ProvenanceInfo, 0)
+		p := NewBytePool(10, 8, ProvenanceHandler(func(info ProvenanceInfo) {
+			infos = append(infos, info)
+		}))
+		if p.Put(make([]byte, 8)) {
+			tt.Errorf("reject foreign put")
+		}
+		if p.Put(make([]byte, 9)) {
+			tt.Errorf("reject foreign put")
+		}
+		if len(infos) != 2 {
+			tt.Fatalf("reported: %d", len(infos))
+		}
+		if infos[0].Err != ErrPutForeign {
+			tt.Errorf("foreign: %v", infos[0].Err)
+		}
+		if s := p.Stats(); s.PutDiscardForeign != 2 {
+			tt.Errorf("foreign = %d", s.PutDiscardForeign)
+		}
+	})
+	t.Run("fullcap", func(tt *testing.T) {
+		p := NewBytePool(1, 8, TrackProvenance(true))
+		d1 := p.Get()
+		d2 := p.Get()
+		p.Put(d1)
+		if p.Put(d2) {
+			tt.Errorf("full capacity")
+		}
+		if p.Stats().PutDiscardForeign != 0 {
+			tt.Errorf("discarded by full capacity")
+		}
+	})
+	t.Run("preload", func(tt *testing.T) {
+		p := NewBytePool(10, 8, TrackProvenance(true), Preload(true))
+		d1 := p.Get()
+		if p.Put(d1) != true {
+			tt.Errorf("preloaded buffer")
+		}
+	})
+	t.Run("ref", func(tt *testing.T) {
+		mp := NewMultiBytePool(
+			MultiBytePoolSize(10, 8),
+			MultiBytePoolOption(TrackProvenance(true)),
+		)
+		r1 := mp.GetRef(4)
+		r1.Release()
+		r2 := mp.GetRef(100) // fallback
+		r2.Release()
+
+		s := mp.Stats()[8]
+		if s.PutAccepted != 1 {
+			tt.Errorf("accepted = %d", s.PutAccepted)
+		}
+		if s.PutDiscardForeign != 0 {
+			tt.Errorf("fallback is discarded by size")
+		}
+	})
+	t.Run("log", func(tt *testing.T) {
+		out := new(bytes.Buffer)
+		log.SetOutput(out)
+		defer log.SetOutput(os.Stderr)
+
+		p1 := NewBytePool(10, 8, TrackProvenance(true))
+		p1.Put(make([]byte, 8))
+		if strings.Contains(out.String(), ErrPutForeign.Error()) != true {
+			tt.Errorf("log without handler: %s", out.String())
+		}
+
+		out.Reset()
+		p2 := NewBytePool(10, 8, ProvenanceHandler(func(ProvenanceInfo) {}))
+		p2.Put(make([]byte, 8))
+		if out.Len() != 0 {
+			tt.Errorf("handler replaces log: %s", out.String())
+		}
+	})
+}
+
+func TestProvenanceBounded(t *testing.T) {
+	t.Run("dropped", func(tt *testing.T) {
+		p := NewBytePool(4, 8, TrackProvenance(true))
+		for i := 0; i < 1000; i += 1 {
+			p.Get() // dropped without Put
+		}
+		if n := len(p.prov.states); 8 < n {
+			tt.Errorf("tracked entries bounded by limit: %d", n)
+		}
+		if n := p.prov.order.Len(); 8 < n {
+			tt.Errorf("lent entries bounded by limit: %d", n)
+		}
+	})
+	t.Run("maxoutstanding", func(tt *testing.T) {
+		p := NewBytePool(4, 8, TrackProvenance(true), MaxOutstanding(2))
+		if p.prov.limit != 6 {
+			tt.Errorf("pool size + MaxOutstanding: %d", p.prov.limit)
+		}
+	})
+	t.Run("discard", func(tt *testing.T) {
+		p := NewBytePool(4, 8, TrackProvenance(true))
+		d1 := p.Get()
+		d2 := p.Get()
+		if p.Put(d1[:4:4]) {
+			tt.Errorf("discard too small")
+		}
+		if p.Put(d2[:8:8]) != true {
+			tt.Errorf("lent buffer")
+		}
+		if _, ok := p.prov.lent[mustProvenanceKey(d1)]; ok {
+			tt.Errorf("discarded buffer is forgotten")
+		}
+		if n := len(p.prov.states); n != 1 {
+			tt.Errorf("only pooled d2 is tracked: %d", n)
+		}
+	})
+}
+
+func mustProvenanceKey(data []byte) uintptr {
+	key, _ := provenanceKey(data)
+	return key
+}
@@ -17,36 +17,110 @@ const (
 type Ref interface {
 	isClosed() bool
 	setFinalizer()
+	finalize()
 	Release()
 }
 
 func finalizeRef(ref Ref) {
 	runtime.SetFinalizer(ref, nil) // clear finalizer
-	ref.Release()
+	ref.finalize()
 }
 
 // compile check
 var (
+	_ Ref = (*ObjectRef[any])(nil)
 	_ Ref = (*ByteRef)(nil)
 	_ Ref = (*BufferRef)(nil)
 	_ Ref = (*BufioReaderRef)(nil)
 	_ Ref = (*BufioWriterRef)(nil)
 	_ Ref = (*ImageRGBARef)(nil)
+	_ Ref = (*ImageNRGBARef)(nil)
+	_ Ref = (*ImageRef[*image.Gray])(nil)
 	_ Ref = (*ImageYCbCrRef)(nil)
 	_ Ref = (*TickerRef)(nil)
 	_ Ref = (*TimerRef)(nil)
 )
 
+type ObjectRef[T any] struct {
+	V      T
+	pool   GetPut[T]
+	lent   T // V as lent by pool, V may be replaced
+	closed int32
+	stack  []uintptr
+}
+
+func (b *ObjectRef[T]) Value() T {
+	return b.V
+}
+
+func (b *ObjectRef[T]) isClosed() bool {
+	return atomic.LoadInt32(&b.closed) == refClosed
+}
+
+func (b *ObjectRef[T]) setFinalizer() {
+	runtime.SetFinalizer(b, finalizeRef)
+}
+
+func (b *ObjectRef[T]) finalize() {
+	b.release(true)
+}
+
+func (b *ObjectRef[T]) Release() {
+	b.release(false)
+}
+
+func (b *ObjectRef[T]) release(finalized bool) {
+	if atomic.CompareAndSwapInt32(&b.closed, refInit, refClosed) {
+		releaseRef(b.pool, finalized, b, b.stack)
+		releaseLent(b.pool, b.lent)
+		b.pool.Put(b.V)
+	}
+}
+
+func newObjectRef[T any](data T, pool GetPut[T]) *ObjectRef[T] {
+	return &ObjectRef[T]{
+		V:      data,
+		pool:   pool,
+		lent:   data,
+		closed: refInit,
+		stack:  acquireRef(pool),
+	}
+}
+
+// byteFlusher writes back []byte to the backing file
+type byteFlusher interface {
+	flush(data []byte, async bool) error
+}
+
 type ByteRef struct {
 	B      []byte
 	pool   ByteGetPut
+	lent   []byte // B as lent by pool, B may be grown or resliced
 	closed int32
+	stack  []uintptr
 }
 
 func (b *ByteRef) Bytes() []byte {
 	return b.B
 }
 
+// Flush writes back B to the backing file and waits for completion (msync MS_SYNC),
+// it does nothing if the pool is not file-backed.
+func (b *ByteRef) Flush() error {
+	if f, ok := b.pool.(byteFlusher); ok {
+		return f.flush(b.B, false)
+	}
+	return nil
+}
+
+// FlushAsync schedules write back of B to the backing file (msync MS_ASYNC)
+func (b *ByteRef) FlushAsync() error {
+	if f, ok := b.pool.(byteFlusher); ok {
+		return f.flush(b.B, true)
+	}
+	return nil
+}
+
 func (b *ByteRef) isClosed() bool {
 	return atomic.LoadInt32(&b.closed) == refClosed
 }
@@ -55,24 +129,48 @@ func (b *ByteRef) setFinalizer() {
 	runtime.SetFinalizer(b, finalizeRef)
 }
 
+func (b *ByteRef) finalize() {
+	b.release(true)
+}
+
 func (b *ByteRef) Release() {
+	b.release(false)
+}
+
+func (b *ByteRef) release(finalized bool) {
 	if atomic.CompareAndSwapInt32(&b.closed, refInit, refClosed) {
+		releaseRef(b.pool, finalized, b, b.stack)
+		releaseLent(b.pool, b.lent)
 		b.pool.Put(b.B)
 	}
 }
 
+// detach closes ref without returning B to pool, caller takes ownership of B
+func (b *ByteRef) detach() bool {
+	if atomic.CompareAndSwapInt32(&b.closed, refInit, refClosed) {
+		runtime.SetFinalizer(b, nil)
+		releaseRef(b.pool, false, b, b.stack)
+		return true
+	}
+	return false
+}
+
 func newByteRef(data []byte, pool ByteGetPut) *ByteRef {
 	return &ByteRef{
 		B:      data,
 		pool:   pool,
+		lent:   data,
 		closed: refInit,
+		stack:  acquireRef(pool),
 	}
 }
 
 type BufferRef struct {
 	Buf    *bytes.Buffer
 	pool   BytesBufferGetPut
+	lent   *bytes.Buffer // Buf as lent by pool
 	closed int32
+	stack  []uintptr
 }
 
 func (b *BufferRef) Buffer() *bytes.Buffer {
@@ -87,8 +185,18 @@ func (b *BufferRef) setFinalizer() {
 	runtime.SetFinalizer(b, finalizeRef)
 }
 
+func (b *BufferRef) finalize() {
+	b.release(true)
+}
+
 func (b *BufferRef) Release() {
+	b.release(false)
+}
+
+func (b *BufferRef) release(finalized bool) {
 	if atomic.CompareAndSwapInt32(&b.closed, refInit, refClosed) {
+		releaseRef(b.pool, finalized, b, b.stack)
+		releaseLent(b.pool, b.lent)
 		b.pool.Put(b.Buf)
 	}
 }
@@ -97,14 +205,18 @@ func newBufferRef(data *bytes.Buffer, pool BytesBufferGetPut) *BufferRef {
 	return &BufferRef{
 		Buf:    data,
 		pool:   pool,
+		lent:   data,
 		closed: refInit,
+		stack:  acquireRef(pool),
 	}
 }
 
 type BufioReaderRef struct {
 	Buf    *bufio.Reader
 	pool   BufioReaderGetPut
+	lent   *bufio.Reader // Buf as lent by pool
 	closed int32
+	stack  []uintptr
 }
 
 func (b *BufioReaderRef) Reader() *bufio.Reader {
@@ -119,8 +231,18 @@ func (b *BufioReaderRef) setFinalizer() {
 	runtime.SetFinalizer(b, finalizeRef)
 }
 
+func (b *BufioReaderRef) finalize() {
+	b.release(true)
+}
+
 func (b *BufioReaderRef) Release() {
+	b.release(false)
+}
+
+func (b *BufioReaderRef) release(finalized bool) {
 	if atomic.CompareAndSwapInt32(&b.closed, refInit, refClosed) {
+		releaseRef(b.pool, finalized, b, b.stack)
+		releaseLent(b.pool, b.lent)
 		b.pool.Put(b.Buf)
 	}
 }
@@ -129,14 +251,18 @@ func newBufioReaderRef(data *bufio.Reader, pool BufioReaderGetPut) *BufioReaderR
 	return &BufioReaderRef{
 		Buf:    data,
 		pool:   pool,
+		lent:   data,
 		closed: refInit,
+		stack:  acquireRef(pool),
 	}
 }
 
 type BufioWriterRef struct {
 	Buf    *bufio.Writer
 	pool   BufioWriterGetPut
+	lent   *bufio.Writer // Buf as lent by pool
 	closed int32
+	stack  []uintptr
 }
 
 func (b *BufioWriterRef) Writer() *bufio.Writer {
@@ -151,8 +277,18 @@ func (b *BufioWriterRef) setFinalizer() {
 	runtime.SetFinalizer(b, finalizeRef)
 }
 
+func (b *BufioWriterRef) finalize() {
+	b.release(true)
+}
+
 func (b *BufioWriterRef) Release() {
+	b.release(false)
+}
+
+func (b *BufioWriterRef) release(finalized bool) {
 	if atomic.CompareAndSwapInt32(&b.closed, refInit, refClosed) {
+		releaseRef(b.pool, finalized, b, b.stack)
+		releaseLent(b.pool, b.lent)
 		b.pool.Put(b.Buf)
 	}
 }
@@ -161,7 +297,9 @@ func newBufioWriterRef(data *bufio.Writer, pool BufioWriterGetPut) *BufioWriterR
 	return &BufioWriterRef{
 		Buf:    data,
 		pool:   pool,
+		lent:   data,
 		closed: refInit,
+		stack:  acquireRef(pool),
 	}
 }
 
@@ -170,6 +308,7 @@ type ImageRGBARef struct {
 	pix    []byte
 	pool   ImageRGBAGetPut
 	closed int32
+	stack  []uintptr
 }
 
 func (b *ImageRGBARef) Image() *image.RGBA {
@@ -184,8 +323,17 @@ func (b *ImageRGBARef) setFinalizer() {
 	runtime.SetFinalizer(b, finalizeRef)
 }
 
+func (b *ImageRGBARef) finalize() {
+	b.release(true)
+}
+
 func (b *ImageRGBARef) Release() {
+	b.release(false)
+}
+
+func (b *ImageRGBARef) release(finalized bool) {
 	if atomic.CompareAndSwapInt32(&b.closed, refInit, refClosed) {
+		releaseRef(b.pool, finalized, b, b.stack)
 		b.pool.Put(b.pix)
 	}
 }
@@ -196,6 +344,7 @@ func newImageRGBARef(pix []byte, img *image.RGBA, pool ImageRGBAGetPut) *ImageRG
 		pix:    pix,
 		pool:   pool,
 		closed: refInit,
+		stack:  acquireRef(pool),
 	}
 }
 
@@ -204,6 +353,7 @@ type ImageNRGBARef struct {
 	pix    []byte
 	pool   ImageNRGBAGetPut
 	closed int32
+	stack  []uintptr
 }
 
 func (b *ImageNRGBARef) Image() *image.NRGBA {
@@ -218,8 +368,17 @@ func (b *ImageNRGBARef) setFinalizer() {
 	runtime.SetFinalizer(b, finalizeRef)
 }
 
+func (b *ImageNRGBARef) finalize() {
+	b.release(true)
+}
+
 func (b *ImageNRGBARef) Release() {
+	b.release(false)
+}
+
+func (b *ImageNRGBARef) release(finalized bool) {
 	if atomic.CompareAndSwapInt32(&b.closed, refInit, refClosed) {
+		releaseRef(b.pool, finalized, b, b.stack)
 		b.pool.Put(b.pix)
 	}
 }
@@ -230,14 +389,74 @@ func newImageNRGBARef(pix []byte, img *image.NRGBA, pool ImageNRGBAGetPut) *Imag
 		pix:    pix,
 		pool:   pool,
 		closed: refInit,
+		stack:  acquireRef(pool),
 	}
 }
 
+// ImageRef is Ref of image that has pixels in pooled []byte
+type ImageRef[I image.Image] struct {
+	Img    I
+	pix    []byte
+	pool   ImageGetPut[I]
+	closed int32
+	stack  []uintptr
+}
+
+func (b *ImageRef[I]) Image() I {
+	return b.Img
+}
+
+func (b *ImageRef[I]) isClosed() bool {
+	return atomic.LoadInt32(&b.closed) == refClosed
+}
+
+func (b *ImageRef[I]) setFinalizer() {
+	runtime.SetFinalizer(b, finalizeRef)
+}
+
+func (b *ImageRef[I]) finalize() {
+	b.release(true)
+}
+
+func (b *ImageRef[I]) Release() {
+	b.release(false)
+}
+
+func (b *ImageRef[I]) release(finalized bool) {
+	if atomic.CompareAndSwapInt32(&b.closed, refInit, refClosed) {
+		releaseRef(b.pool, finalized, b, b.stack)
+		b.pool.Put(b.pix)
+	}
+}
+
+func newImageRef[I image.Image](pix []byte, img I, pool ImageGetPut[I]) *ImageRef[I] {
+	return &ImageRef[I]{
+		Img:    img,
+		pix:    pix,
+		pool:   pool,
+		closed: refInit,
+		stack:  acquireRef(pool),
+	}
+}
+
+type (
+	ImageGrayRef     = ImageRef[*image.Gray]
+	ImageGray16Ref   = ImageRef[*image.Gray16]
+	ImageAlphaRef    = ImageRef[*image.Alpha]
+	ImageAlpha16Ref  = ImageRef[*image.Alpha16]
+	ImageRGBA64Ref   = ImageRef[*image.RGBA64]
+	ImageNRGBA64Ref  = ImageRef[*image.NRGBA64]
+	ImageCMYKRef     = ImageRef[*image.CMYK]
+	ImagePalettedRef = ImageRef[*image.Paletted]
+	ImageNYCbCrARef  = ImageRef[*image.NYCbCrA]
+)
+
 type ImageYCbCrRef struct {
 	Img    *image.YCbCr
 	pix    []byte
 	pool   ImageYCbCrGetPut
 	closed int32
+	stack  []uintptr
 }
 
 func (b *ImageYCbCrRef) Image() *image.YCbCr {
@@ -252,8 +471,17 @@ func (b *ImageYCbCrRef) setFinalizer() {
 	runtime.SetFinalizer(b, finalizeRef)
 }
 
+func (b *ImageYCbCrRef) finalize() {
+	b.release(true)
+}
+
 func (b *ImageYCbCrRef) Release() {
+	b.release(false)
+}
+
+func (b *ImageYCbCrRef) release(finalized bool) {
 	if atomic.CompareAndSwapInt32(&b.closed, refInit, refClosed) {
+		releaseRef(b.pool, finalized, b, b.stack)
 		b.pool.Put(b.pix)
 	}
 }
@@ -264,6 +492,7 @@ func newImageYCbCrRef(pix []byte, img *image.YCbCr, pool ImageYCbCrGetPut) *Imag
 		pix:    pix,
 		pool:   pool,
 		closed: refInit,
+		stack:  acquireRef(pool),
 	}
 }
 
@@ -271,6 +500,7 @@ type TickerRef struct {
 	T      *time.Ticker
 	pool   TickerGetPut
 	closed int32
+	stack  []uintptr
 }
 
 func (b *TickerRef) Ticker() *time.Ticker {
@@ -285,8 +515,17 @@ func (b *TickerRef) setFinalizer() {
 	runtime.SetFinalizer(b, finalizeRef)
 }
 
+func (b *TickerRef) finalize() {
+	b.release(true)
+}
+
 func (b *TickerRef) Release() {
+	b.release(false)
+}
+
+func (b *TickerRef) release(finalized bool) {
 	if atomic.CompareAndSwapInt32(&b.closed, refInit, refClosed) {
+		releaseRef(b.pool, finalized, b, b.stack)
 		b.pool.Put(b.T)
 	}
 }
@@ -296,6 +535,7 @@ func newTickerRef(ticker *time.Ticker, pool TickerGetPut) *TickerRef {
 		T:      ticker,
 		pool:   pool,
 		closed: refInit,
+		stack:  acquireRef(pool),
 	}
 }
 
@@ -303,6 +543,7 @@ type TimerRef struct {
 	T      *time.Timer
 	pool   TimerGetPut
 	closed int32
+	stack  []uintptr
 }
 
 func (b *TimerRef) Ticker() *time.Timer {
@@ -317,8 +558,17 @@ func (b *TimerRef) setFinalizer() {
 	runtime.SetFinalizer(b, finalizeRef)
 }
 
+func (b *TimerRef) finalize() {
+	b.release(true)
+}
+
 func (b *TimerRef) Release() {
+	b.release(false)
+}
+
+func (b *TimerRef) release(finalized bool) {
 	if atomic.CompareAndSwapInt32(&b.closed, refInit, refClosed) {
+		releaseRef(b.pool, finalized, b, b.stack)
 		b.pool.Put(b.T)
 	}
 }
@@ -328,5 +578,6 @@ func newTimerRef(timer *time.Timer, pool TimerGetPut) *TimerRef {
 		T:      timer,
 		pool:   pool,
 		closed: refInit,
+		stack:  acquireRef(pool),
 	}
 }
@@ -0,0 +1,201 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package bp
+
+import (
+	"sort"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// SlabInfo is occupancy of a slab
+type SlabInfo struct {
+	Slots int // number of slots in slab
+	Used  int // number of slots pooled or in use
+}
+
+// slab is a mapping split into fixed size slots
+type slab struct {
+	mem    []byte
+	base   uintptr
+	mapped bool     // false if fallback to heap
+	free   []int32  // free slot indices
+	alloc  []uint64 // bitmap of allocated slots
+	used   int
+}
+
+func (s *slab) allocated(i int) bool {
+	return s.alloc[i/64]&(1<<uint(i%64)) != 0
+}
+
+func (s *slab) mark(i int, allocated bool) {
+	if allocated {
+		s.alloc[i/64] |= 1 << uint(i%64)
+	} else {
+		s.alloc[i/64] &^= 1 << uint(i%64)
+	}
+}
+
+// slabArena allocates fixed size slots from slabs, slab is unmapped when every slot is free
+type slabArena struct {
+	mutex      *sync.Mutex
+	slotSize   int
+	dataOffset int // offset of []byte in slot
+	dataSize   int // cap of []byte
+	slots      int
+	slabs      []*slab // sorted by base
+	mmap       func(int) ([]byte, bool)
+}
+
+func (a *slabArena) alloc() []byte {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for _, s := range a.slabs {
+		if 0 < len(s.free) {
+			return a.allocSlot(s)
+		}
+	}
+	// all slabs are full
+	return a.allocSlot(a.newSlab())
+}
+
+func (a *slabArena) allocSlot(s *slab) []byte {
+	i := int(s.free[len(s.free)-1])
+	s.free = s.free[:len(s.free)-1]
+	s.mark(i, true)
+	s.used += 1
+
+	offset := (i * a.slotSize) + a.dataOffset
+	return s.mem[offset : offset+a.dataSize : offset+a.dataSize]
+}
+
+func (a *slabArena) newSlab() *slab {
+	mem, mapped := a.mmap(a.slotSize * a.slots)
+	free := make([]int32, a.slots)
+	for i := 0; i < a.slots; i += 1 {
+		// lower slot first
+		free[i] = int32(a.slots - 1 - i)
+	}
+	s := &slab{
+		mem:    mem,
+		base:   uintptr(unsafe.Pointer(&mem[0])),
+		mapped: mapped,
+		free:   free,
+		alloc:  make([]uint64, (a.slots+63)/64),
+	}
+
+	i := sort.Search(len(a.slabs), func(n int) bool {
+		return s.base < a.slabs[n].base
+	})
+	a.slabs = append(a.slabs, nil)
+	copy(a.slabs[i+1:], a.slabs[i:])
+	a.slabs[i] = s
+	return s
+}
+
+// find returns index of the slab that contains data
+func (a *slabArena) find(data []byte) (int, int, bool) {
+	if cap(data) != a.dataSize {
+		return 0, 0, false
+	}
+	addr := uintptr(unsafe.Pointer(&data[:1][0]))
+
+	i := sort.Search(len(a.slabs), func(n int) bool {
+		return addr < a.slabs[n].base
+	}) - 1
+	if i < 0 {
+		return 0, 0, false
+	}
+	s := a.slabs[i]
+	offset := int(addr - s.base)
+	if len(s.mem) <= offset || offset%a.slotSize != a.dataOffset {
+		return 0, 0, false
+	}
+	return i, offset / a.slotSize, true
+}
+
+// free returns slot to slab, false if data is not allocated by this arena or slot is already free
+func (a *slabArena) free(data []byte) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	i, slot, ok := a.find(data)
+	if ok != true {
+		return false
+	}
+
+	s := a.slabs[i]
+	if s.allocated(slot) != true {
+		// double free, slot may be lent again or slab may be in use
+		return false
+	}
+	s.mark(slot, false)
+	s.free = append(s.free, int32(slot))
+	s.used -= 1
+	if 0 < s.used {
+		return true
+	}
+
+	// every slot is free, release whole slab
+	a.slabs = append(a.slabs[:i], a.slabs[i+1:]...)
+	if s.mapped {
+		unix.Munmap(s.mem)
+	}
+	return true
+}
+
+// slot returns whole slot that contains data, false if data is not allocated from mapped slab
+func (a *slabArena) slot(data []byte) ([]byte, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	i, slot, ok := a.find(data)
+	if ok != true {
+		return nil, false
+	}
+	s := a.slabs[i]
+	if s.mapped != true {
+		return nil, false
+	}
+	offset := slot * a.slotSize
+	return s.mem[offset : offset+a.slotSize : offset+a.slotSize], true
+}
+
+func (a *slabArena) info() []SlabInfo {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	infos := make([]SlabInfo, len(a.slabs))
+	for i, s := range a.slabs {
+		infos[i] = SlabInfo{
+			Slots: a.slots,
+			Used:  s.used,
+		}
+	}
+	return infos
+}
+
+func newSlabArena(slotSize int, slabSize int, mmap func(int) ([]byte, bool)) *slabArena {
+	return newSlabArenaOffset(slotSize, 0, slotSize, slabSize, mmap)
+}
+
+// newSlabArenaOffset returns arena that allocates []byte of dataSize at dataOffset in each slot
+func newSlabArenaOffset(slotSize, dataOffset, dataSize int, slabSize int, mmap func(int) ([]byte, bool)) *slabArena {
+	slots := slabSize / slotSize
+	if slots < 1 {
+		slots = 1
+	}
+	return &slabArena{
+		mutex:      new(sync.Mutex),
+		slotSize:   slotSize,
+		dataOffset: dataOffset,
+		dataSize:   dataSize,
+		slots:      slots,
+		slabs:      make([]*slab, 0),
+		mmap:       mmap,
+	}
+}
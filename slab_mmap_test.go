@@ -0,0 +1,191 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package bp
+
+import (
+	"testing"
+)
+
+func TestSlabArena(t *testing.T) {
+	mmapCount := 0
+	newArena := func(slotSize, slabSize int) *slabArena {
+		p := NewMmapBytePool(1, slotSize)
+		return newSlabArena(slotSize, slabSize, func(size int) ([]byte, bool) {
+			mmapCount += 1
+			return p.mmap(size)
+		})
+	}
+
+	t.Run("alloc", func(tt *testing.T) {
+		mmapCount = 0
+		a := newArena(16, 16*4)
+		slots := make([][]byte, 0, 8)
+		for i := 0; i < 8; i += 1 {
+			slots = append(slots, a.alloc())
+		}
+		if mmapCount != 2 {
+			tt.Errorf("4 slots per slab: mmap = %d", mmapCount)
+		}
+		for _, s := range slots {
+			if len(s) != 16 || cap(s) != 16 {
+				tt.Errorf("slot size len=%d cap=%d", len(s), cap(s))
+			}
+		}
+		slots[0][15] = 1
+		if slots[1][0] != 0 {
+			tt.Errorf("slots are not overlapped")
+		}
+
+		infos := a.info()
+		if len(infos) != 2 {
+			tt.Fatalf("slabs = %d", len(infos))
+		}
+		for _, info := range infos {
+			if info.Slots != 4 || info.Used != 4 {
+				tt.Errorf("occupancy = %+v", info)
+			}
+		}
+	})
+	t.Run("free", func(tt *testing.T) {
+		mmapCount = 0
+		a := newArena(16, 16*4)
+		slots := make([][]byte, 0, 8)
+		for i := 0; i < 8; i += 1 {
+			slots = append(slots, a.alloc())
+		}
+
+		// reuse free slot without mmap
+		a.free(slots[0])
+		s := a.alloc()
+		if &s[0] != &slots[0][0] {
+			tt.Errorf("reuse free slot")
+		}
+		if mmapCount != 2 {
+			tt.Errorf("mmap = %d", mmapCount)
+		}
+
+		for _, s := range slots[:4] {
+			if a.free(s) != true {
+				tt.Errorf("allocated by arena")
+			}
+		}
+		if len(a.info()) != 1 {
+			tt.Errorf("unmap whole slab: %d", len(a.info()))
+		}
+		for _, s := range slots[4:] {
+			a.free(s)
+		}
+		if len(a.info()) != 0 {
+			tt.Errorf("unmap all slabs: %d", len(a.info()))
+		}
+	})
+	t.Run("foreign", func(tt *testing.T) {
+		a := newArena(16, 16*4)
+		s := a.alloc()
+		if a.free(make([]byte, 16)) {
+			tt.Errorf("not allocated by arena")
+		}
+		if a.free(s[1:]) {
+			tt.Errorf("sub slice is not slot")
+		}
+		if a.info()[0].Used != 1 {
+			tt.Errorf("used = %d", a.info()[0].Used)
+		}
+	})
+	t.Run("doublefree", func(tt *testing.T) {
+		a := newArena(16, 16*4)
+		s1 := a.alloc()
+		s2 := a.alloc()
+		if a.free(s1) != true {
+			tt.Errorf("allocated slot")
+		}
+		if a.free(s1) {
+			tt.Errorf("slot is already free")
+		}
+		if infos := a.info(); len(infos) != 1 || infos[0].Used != 1 {
+			tt.Errorf("s2 keeps slab: %+v", infos)
+		}
+		s3 := a.alloc()
+		s4 := a.alloc()
+		if &s3[0] == &s4[0] || &s3[0] == &s2[0] || &s4[0] == &s2[0] {
+			tt.Errorf("slot allocated twice")
+		}
+	})
+	t.Run("large", func(tt *testing.T) {
+		a := newArena(1024, 100)
+		a.alloc()
+		a.alloc()
+		if infos := a.info(); len(infos) != 2 || infos[0].Slots != 1 {
+			tt.Errorf("1 slot per slab: %+v", infos)
+		}
+	})
+}
+
+func TestMmapBytePoolSlab(t *testing.T) {
+	t.Run("preload", func(tt *testing.T) {
+		p := NewMmapBytePool(10, 8, Preload(true), PreloadRate(1.0), MmapSlabSize(16*5))
+		slabs := p.Slabs()
+		if len(slabs) != 2 {
+			tt.Fatalf("slabs = %d", len(slabs))
+		}
+		for _, s := range slabs {
+			if s.Used != 5 {
+				tt.Errorf("occupancy = %+v", s)
+			}
+		}
+	})
+	t.Run("doubleput", func(tt *testing.T) {
+		p := NewMmapBytePool(0, 100)
+		d1 := p.Get()
+		d2 := p.Get()
+		p.Put(d1)
+		p.Put(d1)
+		if s := p.Slabs(); len(s) != 1 || s[0].Used != 1 {
+			tt.Fatalf("d2 keeps slab: %+v", s)
+		}
+		d2[0] = 1 // still mapped
+		p.Put(d2)
+		if n := len(p.Slabs()); n != 0 {
+			tt.Errorf("slab is not unmapped = %d", n)
+		}
+	})
+	t.Run("full", func(tt *testing.T) {
+		p := NewMmapBytePool(1, 8, MmapSlabSize(16*4))
+		d1 := p.Get()
+		d2 := p.Get()
+		p.Put(d1)
+		if p.Put(d2) {
+			tt.Errorf("full capacity")
+		}
+		if s := p.Slabs(); len(s) != 1 || s[0].Used != 1 {
+			tt.Errorf("discarded slot is free: %+v", s)
+		}
+	})
+	t.Run("trim", func(tt *testing.T) {
+		p := NewMmapBytePool(10, 8, MmapSlabSize(16*4))
+		data := make([][]byte, 0, 4)
+		for i := 0; i < 4; i += 1 {
+			data = append(data, p.Get())
+		}
+		for _, d := range data {
+			p.Put(d)
+		}
+		p.Trim()
+		p.Trim()
+		if len(p.Slabs()) != 0 {
+			tt.Errorf("unmap slab: %+v", p.Slabs())
+		}
+	})
+	t.Run("multi", func(tt *testing.T) {
+		mp := NewMultiMmapBytePool(
+			MultiMmapBytePoolSize(10, 8),
+			MultiMmapBytePoolSize(10, 100),
+		)
+		mp.Put(mp.Get(8))
+		slabs := mp.Slabs()
+		if len(slabs[8]) != 1 || len(slabs[100]) != 0 {
+			tt.Errorf("slabs = %+v", slabs)
+		}
+	})
+}
@@ -0,0 +1,215 @@
+package bp
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Stats is a snapshot of pool counters
+type Stats struct {
+	Len                 int
+	Cap                 int
+	GetHit              uint64 // Get from pool
+	GetMiss             uint64 // Get allocated
+	GetCanceled         uint64 // GetContext canceled while waiting MaxOutstanding
+	PutAccepted         uint64
+	PutDiscardTooBig    uint64
+	PutDiscardTooSmall  uint64
+	PutDiscardFull      uint64
+	PutDiscardRejected  uint64 // rejected by AcceptFunc
+	PutDiscardDuplicate uint64 // rejected by TrackProvenance
+	PutDiscardForeign   uint64 // rejected by TrackProvenance or Alignment
+	PutDiscardBudget    uint64 // exceeds Budget
+	PutDiscardClosed    uint64 // Put after Close
+	RefAcquired         uint64
+	RefReleased         uint64 // released by Release()
+	RefFinalized        uint64 // released by finalizer
+	RefOutstanding      int64
+	Trimmed             uint64 // released by Trim
+}
+
+type poolStats struct {
+	getHit              uint64
+	getMiss             uint64
+	getCanceled         uint64
+	putAccepted         uint64
+	putDiscardTooBig    uint64
+	putDiscardTooSmall  uint64
+	putDiscardFull      uint64
+	putDiscardRejected  uint64
+	putDiscardDuplicate uint64
+	putDiscardForeign   uint64
+	putDiscardBudget    uint64
+	putDiscardClosed    uint64
+	refAcquired         uint64
+	refReleased         uint64
+	refFinalized        uint64
+	trimReleased        uint64
+	drainWaiting        int32
+	drainMutex          *sync.Mutex
+	drainWaiters        []chan struct{}
+}
+
+func (s *poolStats) hit() {
+	atomic.AddUint64(&s.getHit, 1)
+}
+
+func (s *poolStats) miss() {
+	atomic.AddUint64(&s.getMiss, 1)
+}
+
+func (s *poolStats) canceled() {
+	atomic.AddUint64(&s.getCanceled, 1)
+}
+
+func (s *poolStats) accepted() {
+	atomic.AddUint64(&s.putAccepted, 1)
+}
+
+func (s *poolStats) discardTooBig() {
+	atomic.AddUint64(&s.putDiscardTooBig, 1)
+}
+
+func (s *poolStats) discardTooSmall() {
+	atomic.AddUint64(&s.putDiscardTooSmall, 1)
+}
+
+func (s *poolStats) discardFull() {
+	atomic.AddUint64(&s.putDiscardFull, 1)
+}
+
+func (s *poolStats) discardRejected() {
+	atomic.AddUint64(&s.putDiscardRejected, 1)
+}
+
+func (s *poolStats) discardDuplicate() {
+	atomic.AddUint64(&s.putDiscardDuplicate, 1)
+}
+
+func (s *poolStats) discardForeign() {
+	atomic.AddUint64(&s.putDiscardForeign, 1)
+}
+
+func (s *poolStats) discardBudget() {
+	atomic.AddUint64(&s.putDiscardBudget, 1)
+}
+
+func (s *poolStats) discardClosed() {
+	atomic.AddUint64(&s.putDiscardClosed, 1)
+}
+
+func (s *poolStats) acquired() {
+	atomic.AddUint64(&s.refAcquired, 1)
+}
+
+func (s *poolStats) released(finalized bool) {
+	if finalized {
+		atomic.AddUint64(&s.refFinalized, 1)
+	} else {
+		atomic.AddUint64(&s.refReleased, 1)
+	}
+	if 0 < atomic.LoadInt32(&s.drainWaiting) {
+		s.notifyDrained()
+	}
+}
+
+func (s *poolStats) outstanding() int64 {
+	acquired := atomic.LoadUint64(&s.refAcquired)
+	released := atomic.LoadUint64(&s.refReleased)
+	finalized := atomic.LoadUint64(&s.refFinalized)
+	return int64(acquired) - int64(released) - int64(finalized)
+}
+
+// notifyDrained wakes up waitDrained when all Refs are released
+func (s *poolStats) notifyDrained() {
+	s.drainMutex.Lock()
+	defer s.drainMutex.Unlock()
+
+	if 0 < s.outstanding() {
+		return
+	}
+	for _, ch := range s.drainWaiters {
+		close(ch)
+	}
+	s.drainWaiters = s.drainWaiters[:0]
+	atomic.StoreInt32(&s.drainWaiting, 0)
+}
+
+// waitDrained waits until all Refs are released or ctx is done
+func (s *poolStats) waitDrained(ctx context.Context) error {
+	ch := make(chan struct{})
+
+	s.drainMutex.Lock()
+	// mark waiting before check outstanding, so that concurrent release never miss notify
+	atomic.AddInt32(&s.drainWaiting, 1)
+	if s.outstanding() <= 0 {
+		atomic.AddInt32(&s.drainWaiting, -1)
+		s.drainMutex.Unlock()
+		return nil
+	}
+	s.drainWaiters = append(s.drainWaiters, ch)
+	s.drainMutex.Unlock()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		s.cancelDrained(ch)
+		return ctx.Err()
+	}
+}
+
+func (s *poolStats) cancelDrained(ch chan struct{}) {
+	s.drainMutex.Lock()
+	defer s.drainMutex.Unlock()
+
+	for i, c := range s.drainWaiters {
+		if c == ch {
+			s.drainWaiters = append(s.drainWaiters[:i], s.drainWaiters[i+1:]...)
+			atomic.AddInt32(&s.drainWaiting, -1)
+			return
+		}
+	}
+	// already notified
+}
+
+func (s *poolStats) trimmed(n int) {
+	if 0 < n {
+		atomic.AddUint64(&s.trimReleased, uint64(n))
+	}
+}
+
+func (s *poolStats) snapshot(poolLen, poolCap int) Stats {
+	acquired := atomic.LoadUint64(&s.refAcquired)
+	released := atomic.LoadUint64(&s.refReleased)
+	finalized := atomic.LoadUint64(&s.refFinalized)
+	return Stats{
+		Len:                 poolLen,
+		Cap:                 poolCap,
+		GetHit:              atomic.LoadUint64(&s.getHit),
+		GetMiss:             atomic.LoadUint64(&s.getMiss),
+		GetCanceled:         atomic.LoadUint64(&s.getCanceled),
+		PutAccepted:         atomic.LoadUint64(&s.putAccepted),
+		PutDiscardTooBig:    atomic.LoadUint64(&s.putDiscardTooBig),
+		PutDiscardTooSmall:  atomic.LoadUint64(&s.putDiscardTooSmall),
+		PutDiscardFull:      atomic.LoadUint64(&s.putDiscardFull),
+		PutDiscardRejected:  atomic.LoadUint64(&s.putDiscardRejected),
+		PutDiscardDuplicate: atomic.LoadUint64(&s.putDiscardDuplicate),
+		PutDiscardForeign:   atomic.LoadUint64(&s.putDiscardForeign),
+		PutDiscardBudget:    atomic.LoadUint64(&s.putDiscardBudget),
+		PutDiscardClosed:    atomic.LoadUint64(&s.putDiscardClosed),
+		RefAcquired:         acquired,
+		RefReleased:         released,
+		RefFinalized:        finalized,
+		RefOutstanding:      int64(acquired) - int64(released) - int64(finalized),
+		Trimmed:             atomic.LoadUint64(&s.trimReleased),
+	}
+}
+
+func newPoolStats() *poolStats {
+	return &poolStats{
+		drainMutex:   new(sync.Mutex),
+		drainWaiters: make([]chan struct{}, 0),
+	}
+}
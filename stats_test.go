@@ -0,0 +1,169 @@
+package bp
+
+import (
+	"bytes"
+	"image"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestStatsBytePool(t *testing.T) {
+	t.Run("getput", func(tt *testing.T) {
+		p := NewBytePool(2, 8)
+		d1 := p.Get() // miss
+		d2 := p.Get() // miss
+		p.Put(d1)
+		p.Put(d2)
+		p.Put(make([]byte, 8)) // full
+		p.Get()                // hit
+		p.Put(make([]byte, 100))
+		p.Put(make([]byte, 1))
+
+		s := p.Stats()
+		if s.GetHit != 1 {
+			tt.Errorf("hit = %d", s.GetHit)
+		}
+		if s.GetMiss != 2 {
+			tt.Errorf("miss = %d", s.GetMiss)
+		}
+		if s.PutAccepted != 2 {
+			tt.Errorf("accepted = %d", s.PutAccepted)
+		}
+		if s.PutDiscardFull != 1 {
+			tt.Errorf("full = %d", s.PutDiscardFull)
+		}
+		if s.PutDiscardTooBig != 1 {
+			tt.Errorf("too big = %d", s.PutDiscardTooBig)
+		}
+		if s.PutDiscardTooSmall != 1 {
+			tt.Errorf("too small = %d", s.PutDiscardTooSmall)
+		}
+		if s.Len != 1 || s.Cap != 2 {
+			tt.Errorf("len = %d cap = %d", s.Len, s.Cap)
+		}
+	})
+	t.Run("ref", func(tt *testing.T) {
+		p := NewBytePool(10, 8)
+		r1 := p.GetRef()
+		r2 := p.GetRef()
+		_ = p.GetRef()
+
+		s1 := p.Stats()
+		if s1.RefAcquired != 3 {
+			tt.Errorf("acquired = %d", s1.RefAcquired)
+		}
+		if s1.RefOutstanding != 3 {
+			tt.Errorf("outstanding = %d", s1.RefOutstanding)
+		}
+
+		r1.Release()
+		r2.Release()
+		r2.Release()
+
+		s2 := p.Stats()
+		if s2.RefReleased != 2 {
+			tt.Errorf("released = %d", s2.RefReleased)
+		}
+		if s2.RefOutstanding != 1 {
+			tt.Errorf("outstanding = %d", s2.RefOutstanding)
+		}
+	})
+	t.Run("finalizer", func(tt *testing.T) {
+		p := NewBytePool(10, 8)
+		func() {
+			p.GetRef()
+		}()
+		for i := 0; i < 10; i += 1 {
+			runtime.GC()
+			if p.Stats().RefFinalized == 1 {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		s := p.Stats()
+		if s.RefFinalized != 1 {
+			tt.Errorf("finalized = %d", s.RefFinalized)
+		}
+		if s.RefReleased != 0 {
+			tt.Errorf("released = %d", s.RefReleased)
+		}
+		if s.RefOutstanding != 0 {
+			tt.Errorf("outstanding = %d", s.RefOutstanding)
+		}
+	})
+}
+
+func TestStatsPool(t *testing.T) {
+	p := NewPool(10, func() []int {
+		return make([]int, 0, 8)
+	}, AcceptFunc(func(v []int) bool {
+		return cap(v) == 8
+	}))
+	p.Put(make([]int, 0, 8))
+	p.Put(make([]int, 0, 16))
+
+	s := p.Stats()
+	if s.PutAccepted != 1 {
+		t.Errorf("accepted = %d", s.PutAccepted)
+	}
+	if s.PutDiscardRejected != 1 {
+		t.Errorf("rejected = %d", s.PutDiscardRejected)
+	}
+}
+
+func TestStatsBufferPool(t *testing.T) {
+	p := NewBufferPool(10, 8)
+	p.Put(bytes.NewBuffer(make([]byte, 0, 100)))
+	if s := p.Stats(); s.PutDiscardTooBig != 1 {
+		t.Errorf("too big = %d", s.PutDiscardTooBig)
+	}
+}
+
+func TestStatsMultiPool(t *testing.T) {
+	t.Run("byte", func(tt *testing.T) {
+		mp := NewMultiBytePool(
+			MultiBytePoolSize(10, 8),
+			MultiBytePoolSize(10, 16),
+		)
+		mp.Put(mp.Get(4))
+		mp.Put(mp.Get(12))
+		mp.Put(mp.Get(12))
+
+		s := mp.Stats()
+		if len(s) != 2 {
+			tt.Errorf("size class = %d", len(s))
+		}
+		if s[8].PutAccepted != 1 {
+			tt.Errorf("8 accepted = %d", s[8].PutAccepted)
+		}
+		if s[16].PutAccepted != 2 {
+			tt.Errorf("16 accepted = %d", s[16].PutAccepted)
+		}
+		if s[16].GetHit != 1 {
+			tt.Errorf("16 hit = %d", s[16].GetHit)
+		}
+	})
+	t.Run("image", func(tt *testing.T) {
+		r1 := image.Rect(0, 0, 10, 10)
+		r2 := image.Rect(0, 0, 20, 20)
+		mp := NewMultiImageRGBAPool(
+			MultiImagePoolSize(10, r1),
+			MultiImagePoolSize(10, r2),
+		)
+		ref := mp.GetRef(image.Rect(0, 0, 15, 15))
+		ref.Release()
+
+		s := mp.Stats()
+		if s[r1].RefAcquired != 0 {
+			tt.Errorf("r1 acquired = %d", s[r1].RefAcquired)
+		}
+		if s[r2].RefAcquired != 1 {
+			tt.Errorf("r2 acquired = %d", s[r2].RefAcquired)
+		}
+		if s[r2].RefReleased != 1 {
+			tt.Errorf("r2 released = %d", s[r2].RefReleased)
+		}
+	})
+}
@@ -0,0 +1,240 @@
+package bp
+
+import (
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+)
+
+// storage keeps pooled values of Pool[T]
+type storage[T any] interface {
+	push(T) bool
+	pop() (T, bool)
+	len() int
+	cap() int
+}
+
+// compile check
+var (
+	_ storage[any] = (*chanStorage[any])(nil)
+	_ storage[any] = (*shardedStorage[any])(nil)
+	_ storage[any] = (*stackStorage[any])(nil)
+)
+
+// chanStorage is default FIFO storage by buffered channel
+type chanStorage[T any] struct {
+	ch chan T
+}
+
+func (s *chanStorage[T]) push(data T) bool {
+	select {
+	case s.ch <- data:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *chanStorage[T]) pop() (T, bool) {
+	select {
+	case data := <-s.ch:
+		return data, true
+	default:
+		var empty T
+		return empty, false
+	}
+}
+
+func (s *chanStorage[T]) len() int {
+	return len(s.ch)
+}
+
+func (s *chanStorage[T]) cap() int {
+	return cap(s.ch)
+}
+
+func newChanStorage[T any](size int) *chanStorage[T] {
+	return &chanStorage[T]{
+		ch: make(chan T, size),
+	}
+}
+
+// shardedStorage splits capacity into multiple channels to reduce lock contention,
+// a goroutine uses the shard selected by its stack address and steals from other shards when it is empty.
+type shardedStorage[T any] struct {
+	shards []storage[T]
+	size   int
+}
+
+func (s *shardedStorage[T]) index() int {
+	// goroutines have different stacks, so address of local variable is a cheap hint of goroutine
+	var v byte
+	addr := uint64(uintptr(unsafe.Pointer(&v)))
+	// stacks are aligned, mix the bits by fibonacci hashing
+	h := ((addr >> 12) * 0x9e3779b97f4a7c15) >> 32
+	return int(h % uint64(len(s.shards)))
+}
+
+func (s *shardedStorage[T]) push(data T) bool {
+	n := len(s.shards)
+	i := s.index()
+	for c := 0; c < n; c += 1 {
+		if s.shards[(i+c)%n].push(data) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *shardedStorage[T]) pop() (T, bool) {
+	n := len(s.shards)
+	i := s.index()
+	for c := 0; c < n; c += 1 {
+		// steal from other shard when own shard is empty
+		if data, ok := s.shards[(i+c)%n].pop(); ok {
+			return data, true
+		}
+	}
+	var empty T
+	return empty, false
+}
+
+func (s *shardedStorage[T]) len() int {
+	size := 0
+	for _, shard := range s.shards {
+		size += shard.len()
+	}
+	return size
+}
+
+func (s *shardedStorage[T]) cap() int {
+	return s.size
+}
+
+func newShardedStorage[T any](size int, shards int, newShard func(int) storage[T]) *shardedStorage[T] {
+	if shards < 1 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+	if size < shards {
+		shards = size
+	}
+	if shards < 1 {
+		shards = 1
+	}
+
+	s := make([]storage[T], shards)
+	for i := 0; i < shards; i += 1 {
+		shardSize := size / shards
+		if i < size%shards {
+			shardSize += 1
+		}
+		s[i] = newShard(shardSize)
+	}
+	return &shardedStorage[T]{
+		shards: s,
+		size:   size,
+	}
+}
+
+const (
+	stackNil uint32 = 0 // index of stack node is 1-origin, 0 is nil
+)
+
+// stackStorage is lock-free LIFO storage (Treiber stack), the most recently pushed value is reused first.
+// nodes are preallocated and linked by index, head is tagged by counter in upper 32 bits to avoid ABA problem.
+type stackStorage[T any] struct {
+	head   uint64 // tagged index of top of values
+	free   uint64 // tagged index of top of free nodes
+	size   int64
+	next   []uint32
+	values []T
+}
+
+func (s *stackStorage[T]) popNode(head *uint64) (uint32, bool) {
+	for {
+		old := atomic.LoadUint64(head)
+		idx := uint32(old)
+		if idx == stackNil {
+			return stackNil, false
+		}
+		next := atomic.LoadUint32(&s.next[idx-1])
+		tag := (old >> 32) + 1
+		if atomic.CompareAndSwapUint64(head, old, (tag<<32)|uint64(next)) {
+			return idx, true
+		}
+	}
+}
+
+func (s *stackStorage[T]) pushNode(head *uint64, idx uint32) {
+	for {
+		old := atomic.LoadUint64(head)
+		atomic.StoreUint32(&s.next[idx-1], uint32(old))
+		tag := (old >> 32) + 1
+		if atomic.CompareAndSwapUint64(head, old, (tag<<32)|uint64(idx)) {
+			return
+		}
+	}
+}
+
+func (s *stackStorage[T]) push(data T) bool {
+	idx, ok := s.popNode(&s.free)
+	if ok != true {
+		// full capacity
+		return false
+	}
+	s.values[idx-1] = data
+	s.pushNode(&s.head, idx)
+	atomic.AddInt64(&s.size, 1)
+	return true
+}
+
+func (s *stackStorage[T]) pop() (T, bool) {
+	var empty T
+	idx, ok := s.popNode(&s.head)
+	if ok != true {
+		return empty, false
+	}
+	data := s.values[idx-1]
+	s.values[idx-1] = empty // dont keep reference
+	s.pushNode(&s.free, idx)
+	atomic.AddInt64(&s.size, -1)
+	return data, true
+}
+
+func (s *stackStorage[T]) len() int {
+	if n := atomic.LoadInt64(&s.size); 0 < n {
+		return int(n)
+	}
+	return 0
+}
+
+func (s *stackStorage[T]) cap() int {
+	return len(s.values)
+}
+
+func newStackStorage[T any](size int) *stackStorage[T] {
+	s := &stackStorage[T]{
+		head:   uint64(stackNil),
+		free:   uint64(stackNil),
+		next:   make([]uint32, size),
+		values: make([]T, size),
+	}
+	for i := size; 0 < i; i -= 1 {
+		s.pushNode(&s.free, uint32(i))
+	}
+	return s
+}
+
+func newStorage[T any](size int, opt *option) storage[T] {
+	newShard := func(shardSize int) storage[T] {
+		if opt.lifo {
+			return newStackStorage[T](shardSize)
+		}
+		return newChanStorage[T](shardSize)
+	}
+
+	if opt.sharded {
+		return newShardedStorage[T](size, opt.shards, newShard)
+	}
+	return newShard(size)
+}
@@ -0,0 +1,279 @@
+package bp
+
+import (
+	"bytes"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func BenchmarkShardedPool(b *testing.B) {
+	b.Run("byte", func(tb *testing.B) {
+		tb.Run("syncpool", func(bb *testing.B) {
+			p := &sync.Pool{
+				New: func() interface{} {
+					return make([]byte, 4096)
+				},
+			}
+			bb.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					s := p.Get().([]byte)
+					s[0] = 1
+					p.Put(s)
+				}
+			})
+		})
+		tb.Run("channel", func(bb *testing.B) {
+			p := NewBytePool(runtime.GOMAXPROCS(0)*4, 4096)
+			bb.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					s := p.Get()
+					s[0] = 1
+					p.Put(s)
+				}
+			})
+		})
+		tb.Run("sharded", func(bb *testing.B) {
+			p := NewBytePool(runtime.GOMAXPROCS(0)*4, 4096, Sharded(0))
+			bb.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					s := p.Get()
+					s[0] = 1
+					p.Put(s)
+				}
+			})
+		})
+		tb.Run("lifo", func(bb *testing.B) {
+			p := NewBytePool(runtime.GOMAXPROCS(0)*4, 4096, LIFO(true))
+			bb.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					s := p.Get()
+					s[0] = 1
+					p.Put(s)
+				}
+			})
+		})
+	})
+	b.Run("buffer", func(tb *testing.B) {
+		tb.Run("syncpool", func(bb *testing.B) {
+			p := &sync.Pool{
+				New: func() interface{} {
+					return bytes.NewBuffer(make([]byte, 0, 4096))
+				},
+			}
+			bb.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					s := p.Get().(*bytes.Buffer)
+					s.WriteByte(1)
+					s.Reset()
+					p.Put(s)
+				}
+			})
+		})
+		tb.Run("channel", func(bb *testing.B) {
+			p := NewBufferPool(runtime.GOMAXPROCS(0)*4, 4096)
+			bb.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					s := p.Get()
+					s.WriteByte(1)
+					p.Put(s)
+				}
+			})
+		})
+		tb.Run("sharded", func(bb *testing.B) {
+			p := NewBufferPool(runtime.GOMAXPROCS(0)*4, 4096, Sharded(0))
+			bb.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					s := p.Get()
+					s.WriteByte(1)
+					p.Put(s)
+				}
+			})
+		})
+	})
+}
+
+func TestShardedStorage(t *testing.T) {
+	t.Run("cap", func(tt *testing.T) {
+		for _, v := range [][2]int{{10, 4}, {3, 8}, {0, 4}, {7, 7}} {
+			s := newShardedStorage[int](v[0], v[1], func(size int) storage[int] {
+				return newChanStorage[int](size)
+			})
+			if s.cap() != v[0] {
+				tt.Errorf("cap(%d, %d) = %d", v[0], v[1], s.cap())
+			}
+			total := 0
+			for _, shard := range s.shards {
+				total += shard.cap()
+			}
+			if total != v[0] {
+				tt.Errorf("total shard cap(%d, %d) = %d", v[0], v[1], total)
+			}
+		}
+	})
+	t.Run("pushpop", func(tt *testing.T) {
+		s := newShardedStorage[int](10, 4, func(size int) storage[int] {
+			return newChanStorage[int](size)
+		})
+		for i := 0; i < 10; i += 1 {
+			if s.push(i) != true {
+				tt.Errorf("free capacity %d", i)
+			}
+		}
+		if s.push(10) {
+			tt.Errorf("full capacity")
+		}
+		if s.len() != 10 {
+			tt.Errorf("len = %d", s.len())
+		}
+
+		// steal from all shards
+		seen := make(map[int]bool)
+		for i := 0; i < 10; i += 1 {
+			v, ok := s.pop()
+			if ok != true {
+				tt.Fatalf("pop %d", i)
+			}
+			seen[v] = true
+		}
+		if len(seen) != 10 {
+			tt.Errorf("all values: %v", seen)
+		}
+		if _, ok := s.pop(); ok {
+			tt.Errorf("empty")
+		}
+	})
+	t.Run("concurrent", func(tt *testing.T) {
+		p := NewBytePool(16, 8, Sharded(4))
+		wg := new(sync.WaitGroup)
+		for i := 0; i < 8; i += 1 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 1000; j += 1 {
+					d := p.Get()
+					p.Put(d)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if 16 < p.Len() {
+			tt.Errorf("len = %d", p.Len())
+		}
+		if p.Cap() != 16 {
+			tt.Errorf("cap = %d", p.Cap())
+		}
+	})
+}
+
+func TestShardedPool(t *testing.T) {
+	p := NewBytePool(12, 8, Sharded(3), Preload(true))
+	l := int(float64(12) * defaultPreloadRate)
+	if p.Len() != l {
+		t.Errorf("preloaded buffer = %d", p.Len())
+	}
+	if p.Cap() != 12 {
+		t.Errorf("cap = %d", p.Cap())
+	}
+}
+
+func TestStackStorage(t *testing.T) {
+	t.Run("lifo", func(tt *testing.T) {
+		s := newStackStorage[int](3)
+		for i := 1; i <= 3; i += 1 {
+			if s.push(i) != true {
+				tt.Errorf("free capacity %d", i)
+			}
+		}
+		if s.push(4) {
+			tt.Errorf("full capacity")
+		}
+		if s.len() != 3 || s.cap() != 3 {
+			tt.Errorf("len = %d cap = %d", s.len(), s.cap())
+		}
+		for i := 3; 1 <= i; i -= 1 {
+			v, ok := s.pop()
+			if ok != true {
+				tt.Fatalf("pop %d", i)
+			}
+			if v != i {
+				tt.Errorf("last in first out: expect=%d actual=%d", i, v)
+			}
+		}
+		if _, ok := s.pop(); ok {
+			tt.Errorf("empty")
+		}
+		if s.len() != 0 {
+			tt.Errorf("len = %d", s.len())
+		}
+	})
+	t.Run("zero", func(tt *testing.T) {
+		s := newStackStorage[int](0)
+		if s.push(1) {
+			tt.Errorf("no capacity")
+		}
+		if _, ok := s.pop(); ok {
+			tt.Errorf("empty")
+		}
+	})
+	t.Run("concurrent", func(tt *testing.T) {
+		s := newStackStorage[*int](8)
+		wg := new(sync.WaitGroup)
+		for i := 0; i < 8; i += 1 {
+			wg.Add(1)
+			go func(n int) {
+				defer wg.Done()
+				own := &n
+				for j := 0; j < 1000; j += 1 {
+					if s.push(own) != true {
+						tt.Errorf("capacity is enough for all goroutines")
+						return
+					}
+					v, ok := s.pop()
+					if ok != true {
+						tt.Errorf("pushed value exists")
+						return
+					}
+					own = v
+				}
+				s.push(own)
+			}(i)
+		}
+		wg.Wait()
+
+		if s.len() != 8 {
+			tt.Errorf("len = %d", s.len())
+		}
+		seen := make(map[*int]bool)
+		for i := 0; i < 8; i += 1 {
+			v, _ := s.pop()
+			seen[v] = true
+		}
+		if len(seen) != 8 {
+			tt.Errorf("values are not duplicated: %d", len(seen))
+		}
+	})
+}
+
+func TestLIFOPool(t *testing.T) {
+	p := NewBytePool(10, 8, LIFO(true))
+	d1 := p.Get()
+	d2 := p.Get()
+	d1[0] = 1
+	d2[0] = 2
+	p.Put(d1)
+	p.Put(d2)
+
+	if d := p.Get(); d[0] != 2 {
+		t.Errorf("most recently put buffer first")
+	}
+	if p.Cap() != 10 {
+		t.Errorf("cap = %d", p.Cap())
+	}
+
+	sp := NewBytePool(16, 8, LIFO(true), Sharded(4), Preload(true))
+	if sp.Len() != 4 || sp.Cap() != 16 {
+		t.Errorf("len = %d cap = %d", sp.Len(), sp.Cap())
+	}
+}
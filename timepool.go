@@ -1,11 +1,12 @@
 package bp
 
 import (
+	"context"
 	"time"
 )
 
 type TickerPool struct {
-	pool chan *time.Ticker
+	pool *Pool[*time.Ticker]
 }
 
 func (b *TickerPool) GetRef(dur time.Duration) *TickerRef {
@@ -17,34 +18,51 @@ func (b *TickerPool) GetRef(dur time.Duration) *TickerRef {
 }
 
 func (b *TickerPool) Get(dur time.Duration) *time.Ticker {
-	select {
-	case t := <-b.pool:
+	if t, ok := b.pool.get(); ok {
 		// reuse exists pool
 		t.Reset(dur)
 		return t
-	default:
-		// create *time.Ticker
-		return time.NewTicker(dur)
 	}
+	// create *time.Ticker
+	return time.NewTicker(dur)
 }
 
 func (b *TickerPool) Put(t *time.Ticker) bool {
 	t.Stop()
 
-	select {
-	case b.pool <- t:
-		return true
-	default:
-		return false
-	}
+	return b.pool.Put(t)
 }
 
 func (b *TickerPool) Len() int {
-	return len(b.pool)
+	return b.pool.Len()
 }
 
 func (b *TickerPool) Cap() int {
-	return cap(b.pool)
+	return b.pool.Cap()
+}
+
+func (b *TickerPool) Stats() Stats {
+	return b.pool.Stats()
+}
+
+func (b *TickerPool) Trim() int {
+	return b.pool.Trim()
+}
+
+func (b *TickerPool) Close() error {
+	return b.pool.Close()
+}
+
+func (b *TickerPool) Drain(ctx context.Context) error {
+	return b.pool.Drain(ctx)
+}
+
+func (b *TickerPool) poolStats() *poolStats {
+	return b.pool.stats
+}
+
+func (b *TickerPool) leakDetector() *leakDetector {
+	return b.pool.leak
 }
 
 func NewTickerPool(poolSize int, funcs ...optionFunc) *TickerPool {
@@ -53,13 +71,16 @@ func NewTickerPool(poolSize int, funcs ...optionFunc) *TickerPool {
 		fn(opt)
 	}
 
-	return &TickerPool{
-		pool: make(chan *time.Ticker, poolSize),
+	b := &TickerPool{
+		// *time.Ticker is created on Get(dur)
+		pool: newPool[*time.Ticker](poolSize, nil, opt),
 	}
+	b.pool.dispose = stopTicker
+	return b
 }
 
 type TimerPool struct {
-	pool chan *time.Timer
+	pool *Pool[*time.Timer]
 }
 
 func (b *TimerPool) GetRef(dur time.Duration) *TimerRef {
@@ -71,15 +92,13 @@ func (b *TimerPool) GetRef(dur time.Duration) *TimerRef {
 }
 
 func (b *TimerPool) Get(dur time.Duration) *time.Timer {
-	select {
-	case t := <-b.pool:
+	if t, ok := b.pool.get(); ok {
 		// reuse exists pool
 		t.Reset(dur)
 		return t
-	default:
-		// create *time.Ticker
-		return time.NewTimer(dur)
 	}
+	// create *time.Timer
+	return time.NewTimer(dur)
 }
 
 func (b *TimerPool) Put(t *time.Timer) bool {
@@ -92,20 +111,39 @@ func (b *TimerPool) Put(t *time.Timer) bool {
 		}
 	}
 
-	select {
-	case b.pool <- t:
-		return true
-	default:
-		return false
-	}
+	return b.pool.Put(t)
 }
 
 func (b *TimerPool) Len() int {
-	return len(b.pool)
+	return b.pool.Len()
 }
 
 func (b *TimerPool) Cap() int {
-	return cap(b.pool)
+	return b.pool.Cap()
+}
+
+func (b *TimerPool) Stats() Stats {
+	return b.pool.Stats()
+}
+
+func (b *TimerPool) Trim() int {
+	return b.pool.Trim()
+}
+
+func (b *TimerPool) Close() error {
+	return b.pool.Close()
+}
+
+func (b *TimerPool) Drain(ctx context.Context) error {
+	return b.pool.Drain(ctx)
+}
+
+func (b *TimerPool) poolStats() *poolStats {
+	return b.pool.stats
+}
+
+func (b *TimerPool) leakDetector() *leakDetector {
+	return b.pool.leak
 }
 
 func NewTimerPool(poolSize int, funcs ...optionFunc) *TimerPool {
@@ -114,7 +152,18 @@ func NewTimerPool(poolSize int, funcs ...optionFunc) *TimerPool {
 		fn(opt)
 	}
 
-	return &TimerPool{
-		pool: make(chan *time.Timer, poolSize),
+	b := &TimerPool{
+		// *time.Timer is created on Get(dur)
+		pool: newPool[*time.Timer](poolSize, nil, opt),
 	}
+	b.pool.dispose = stopTimer
+	return b
+}
+
+func stopTicker(t *time.Ticker) {
+	t.Stop()
+}
+
+func stopTimer(t *time.Timer) {
+	t.Stop()
 }
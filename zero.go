@@ -0,0 +1,26 @@
+package bp
+
+// ZeroPolicy is when pooled buffers are wiped
+type ZeroPolicy uint8
+
+const (
+	ZeroNone   ZeroPolicy = iota // contents of Put are handed to next Get
+	ZeroOnPut                    // wipe buffer when it is returned to pool
+	ZeroOnGet                    // wipe buffer before Get returns it
+	ZeroSecure                   // ZeroOnPut, MmapBytePool also mlocks and excludes pages from core dump
+)
+
+func (z ZeroPolicy) onPut() bool {
+	return z == ZeroOnPut || z == ZeroSecure
+}
+
+func (z ZeroPolicy) onGet() bool {
+	return z == ZeroOnGet
+}
+
+func zeroBytes(data []byte) {
+	buf := data[:cap(data)]
+	for i := range buf {
+		buf[i] = 0
+	}
+}